@@ -0,0 +1,322 @@
+package netgearlogs
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Defaults for AnomalyModel.
+const (
+	DefaultAnomalyAlpha      = 0.2
+	DefaultAnomalyK          = 3.0
+	DefaultAnomalyMinSamples = 3
+	DefaultAnomalyMinSigma   = 1.0
+	DefaultAnomalyMaxGap     = 24 * time.Hour
+)
+
+// DefaultAnomalyKinds are the kinds NewAnomalyModel watches: DoS attacks, DHCP
+// leases, whose churn shows devices coming and going, and WAN (re)connects.
+var DefaultAnomalyKinds = []string{eventDoSAttack, eventDHCPIP, eventInternetConnected}
+
+// anomalyModelVersion is the version of the serialised model state.
+const anomalyModelVersion = 1
+
+// Anomaly is an hour in which the count of an event kind strayed from its baseline.
+type Anomaly struct {
+	Kind string
+	// Hour is the start of the hour.
+	Hour  time.Time
+	Count int
+	// Expected and Sigma are the baseline mean and standard deviation for the hour of
+	// the week, and Score how many sigmas Count is from Expected.
+	Expected float64
+	Sigma    float64
+	Score    float64
+}
+
+// Alert returns the anomaly as an Alert.
+func (a Anomaly) Alert() Alert {
+	direction := "above"
+	if a.Score < 0 {
+		direction = "below"
+	}
+	return Alert{
+		Rule:     "rate-anomaly",
+		Key:      "rate-anomaly:" + a.Kind,
+		Time:     a.Hour,
+		Severity: 5,
+		Summary: fmt.Sprintf("%d %s entries in the hour from %s, %.1f sigma %s the expected %.1f",
+			a.Count, a.Kind, a.Hour.Format("2006-01-02 15:04"), math.Abs(a.Score), direction, a.Expected),
+	}
+}
+
+// anomalySlot is the baseline for one hour of the week.
+type anomalySlot struct {
+	Mean float64 `json:"mean"`
+	Var  float64 `json:"var"`
+	N    int     `json:"n"`
+}
+
+// AnomalyModel learns a baseline rate for event kinds and flags hours that stray
+// from it. The baseline is an exponentially weighted moving average and variance of
+// the hourly count, kept separately for each of the 168 hours of the week, so an hour
+// is compared with the same hour in previous weeks. All DoS attack types are counted
+// together as "DoS Attack".
+//
+// Feed entries in time order to Observe. An hour is scored when the first entry of a
+// later hour arrives, or on Flush. The learned state can be saved and restored with
+// encoding/json. An AnomalyModel is not safe for concurrent use.
+type AnomalyModel struct {
+	// Kinds are the event kinds modelled. If empty, every kind seen is modelled.
+	Kinds []string
+	// Alpha is the weight given to each new week's count.
+	Alpha float64
+	// K is the number of standard deviations beyond which an hour is anomalous.
+	K float64
+	// MinSamples is the number of weeks an hour of the week must have been seen
+	// before it is scored.
+	MinSamples int
+	// MinSigma is the smallest standard deviation used, so that a perfectly regular
+	// baseline does not flag every small change.
+	MinSigma float64
+	// MaxGap is the longest run of hours without entries that is taken as hours with
+	// no events. A longer gap is taken as missing log, and not learned from.
+	MaxGap time.Duration
+	// Location is the time zone hours of the week are counted in. Defaults to UTC. It
+	// is not saved with the model state.
+	Location *time.Location
+
+	baselines map[string]*[hoursPerWeek]anomalySlot
+	hour      time.Time
+	counts    map[string]int
+}
+
+// NewAnomalyModel returns an untrained model with the default settings.
+func NewAnomalyModel() *AnomalyModel {
+	return &AnomalyModel{
+		Kinds:      append([]string(nil), DefaultAnomalyKinds...),
+		Alpha:      DefaultAnomalyAlpha,
+		K:          DefaultAnomalyK,
+		MinSamples: DefaultAnomalyMinSamples,
+		MinSigma:   DefaultAnomalyMinSigma,
+		MaxGap:     DefaultAnomalyMaxGap,
+		baselines:  make(map[string]*[hoursPerWeek]anomalySlot),
+		counts:     make(map[string]int),
+	}
+}
+
+func anomalyKind(l *NetGearLog) string {
+	kind := l.Kind()
+	if strings.HasPrefix(kind, eventDoSAttack) {
+		return eventDoSAttack
+	}
+	return kind
+}
+
+func (m *AnomalyModel) location() *time.Location {
+	if m.Location == nil {
+		return time.UTC
+	}
+	return m.Location
+}
+
+// hourStart returns the start of the hour holding t, in the model's location.
+func (m *AnomalyModel) hourStart(t time.Time) time.Time {
+	t = t.In(m.location())
+	return t.Add(-time.Duration(t.Minute())*time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+}
+
+// Observe counts l and returns the anomalies found in any hours it closes. Entries
+// from before the current hour are ignored.
+func (m *AnomalyModel) Observe(l *NetGearLog) []Anomaly {
+	if m.baselines == nil {
+		m.baselines = make(map[string]*[hoursPerWeek]anomalySlot)
+	}
+	if m.counts == nil {
+		m.counts = make(map[string]int)
+	}
+	h := m.hourStart(l.Time)
+	var anomalies []Anomaly
+	switch {
+	case m.hour.IsZero():
+		m.hour = h
+	case h.Before(m.hour):
+		return nil
+	case h.After(m.hour):
+		anomalies = m.closeHour()
+		if h.Sub(m.hour) <= m.MaxGap {
+			for t := m.hour.Add(time.Hour); t.Before(h); t = t.Add(time.Hour) {
+				m.hour = t
+				anomalies = append(anomalies, m.closeHour()...)
+			}
+		}
+		m.hour = h
+	}
+	if kind := anomalyKind(l); m.watches(kind) {
+		m.counts[kind]++
+	}
+	return anomalies
+}
+
+func (m *AnomalyModel) watches(kind string) bool {
+	if len(m.Kinds) == 0 {
+		return true
+	}
+	for _, k := range m.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Flush scores and learns from the current hour, as if it had ended.
+func (m *AnomalyModel) Flush() []Anomaly {
+	if m.hour.IsZero() {
+		return nil
+	}
+	anomalies := m.closeHour()
+	m.hour = time.Time{}
+	return anomalies
+}
+
+// closeHour scores the counts for the current hour against the baselines, then
+// updates the baselines with them. Every kind watched or seen before gets a count, if
+// only zero.
+func (m *AnomalyModel) closeHour() []Anomaly {
+	slot := int(m.hour.Weekday())*24 + m.hour.Hour()
+	for _, kind := range m.Kinds {
+		m.baseline(kind)
+	}
+	for kind := range m.counts {
+		m.baseline(kind)
+	}
+	var kinds []string
+	for kind := range m.baselines {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	var anomalies []Anomaly
+	for _, kind := range kinds {
+		s := &m.baselines[kind][slot]
+		c := float64(m.counts[kind])
+		if s.N >= m.MinSamples {
+			sigma := math.Max(math.Sqrt(s.Var), m.MinSigma)
+			if score := (c - s.Mean) / sigma; math.Abs(score) > m.K {
+				anomalies = append(anomalies, Anomaly{
+					Kind:     kind,
+					Hour:     m.hour,
+					Count:    m.counts[kind],
+					Expected: s.Mean,
+					Sigma:    sigma,
+					Score:    score,
+				})
+			}
+		}
+		if s.N == 0 {
+			s.Mean = c
+		} else {
+			diff := c - s.Mean
+			incr := m.Alpha * diff
+			s.Mean += incr
+			s.Var = (1 - m.Alpha) * (s.Var + diff*incr)
+		}
+		s.N++
+	}
+	m.counts = make(map[string]int)
+	return anomalies
+}
+
+// baseline returns the baselines for kind, adding them if it has none.
+func (m *AnomalyModel) baseline(kind string) *[hoursPerWeek]anomalySlot {
+	b, ok := m.baselines[kind]
+	if !ok {
+		b = new([hoursPerWeek]anomalySlot)
+		m.baselines[kind] = b
+	}
+	return b
+}
+
+// Expected returns the baseline mean and standard deviation of kind for the hour of
+// the week holding t, and the number of weeks it was learned from.
+func (m *AnomalyModel) Expected(kind string, t time.Time) (mean, sigma float64, samples int) {
+	b, ok := m.baselines[kind]
+	if !ok {
+		return 0, 0, 0
+	}
+	h := m.hourStart(t)
+	s := b[int(h.Weekday())*24+h.Hour()]
+	return s.Mean, math.Sqrt(s.Var), s.N
+}
+
+type anomalyModelJSON struct {
+	Version    int                                   `json:"version"`
+	Kinds      []string                              `json:"kinds,omitempty"`
+	Alpha      float64                               `json:"alpha"`
+	K          float64                               `json:"k"`
+	MinSamples int                                   `json:"min_samples"`
+	MinSigma   float64                               `json:"min_sigma"`
+	MaxGap     string                                `json:"max_gap"`
+	Hour       *time.Time                            `json:"hour,omitempty"`
+	Counts     map[string]int                        `json:"counts,omitempty"`
+	Baselines  map[string]*[hoursPerWeek]anomalySlot `json:"baselines"`
+}
+
+// MarshalJSON saves the model's settings and learned state, including the counts for
+// the hour in progress.
+func (m *AnomalyModel) MarshalJSON() ([]byte, error) {
+	j := anomalyModelJSON{
+		Version:    anomalyModelVersion,
+		Kinds:      m.Kinds,
+		Alpha:      m.Alpha,
+		K:          m.K,
+		MinSamples: m.MinSamples,
+		MinSigma:   m.MinSigma,
+		MaxGap:     m.MaxGap.String(),
+		Counts:     m.counts,
+		Baselines:  m.baselines,
+	}
+	if !m.hour.IsZero() {
+		h := m.hour.UTC()
+		j.Hour = &h
+	}
+	if j.Baselines == nil {
+		j.Baselines = make(map[string]*[hoursPerWeek]anomalySlot)
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON restores a model saved with MarshalJSON. Location is left as it is.
+func (m *AnomalyModel) UnmarshalJSON(data []byte) error {
+	var j anomalyModelJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if j.Version != anomalyModelVersion {
+		return fmt.Errorf("unsupported anomaly model version %d", j.Version)
+	}
+	gap, err := time.ParseDuration(j.MaxGap)
+	if err != nil {
+		return fmt.Errorf("bad max_gap: %s", err)
+	}
+	m.Kinds = j.Kinds
+	m.Alpha, m.K, m.MinSamples, m.MinSigma, m.MaxGap = j.Alpha, j.K, j.MinSamples, j.MinSigma, gap
+	m.baselines = j.Baselines
+	if m.baselines == nil {
+		m.baselines = make(map[string]*[hoursPerWeek]anomalySlot)
+	}
+	m.counts = j.Counts
+	if m.counts == nil {
+		m.counts = make(map[string]int)
+	}
+	m.hour = time.Time{}
+	if j.Hour != nil {
+		m.hour = j.Hour.In(m.location())
+	}
+	return nil
+}
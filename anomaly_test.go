@@ -0,0 +1,97 @@
+package netgearlogs
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// anomalyWeeks returns five weeks of two DoS attacks an hour, with twenty in the hour
+// from spike.
+func anomalyWeeks(start, spike time.Time) []*NetGearLog {
+	var logs []*NetGearLog
+	for h := start; h.Before(start.Add(5 * 7 * 24 * time.Hour)); h = h.Add(time.Hour) {
+		n := 2
+		if h.Equal(spike) {
+			n = 20
+		}
+		for i := 0; i < n; i++ {
+			logs = append(logs, dosAt("37.59.134.139", 80, h.Add(time.Duration(i)*time.Minute)))
+		}
+	}
+	return logs
+}
+
+func TestAnomalyModel(t *testing.T) {
+	start := time.Date(2016, 1, 3, 0, 0, 0, 0, time.UTC)
+	spike := start.Add((4*7*24 + 30) * time.Hour)
+	m := NewAnomalyModel()
+	var found []Anomaly
+	for _, l := range anomalyWeeks(start, spike) {
+		found = append(found, m.Observe(l)...)
+	}
+	found = append(found, m.Flush()...)
+	if len(found) != 1 {
+		t.Fatalf("Expected 1 anomaly, got %+v", found)
+	}
+	a := found[0]
+	if a.Kind != eventDoSAttack || !a.Hour.Equal(spike) || a.Count != 20 || a.Expected != 2 || a.Score != 18 {
+		t.Errorf("Unexpected anomaly %+v", a)
+	}
+	if alert := a.Alert(); alert.Key != "rate-anomaly:DoS Attack" || !alert.Time.Equal(spike) {
+		t.Errorf("Unexpected alert %+v", alert)
+	}
+	if mean, _, n := m.Expected(eventDHCPIP, spike); mean != 0 || n != 5 {
+		t.Errorf("Expected 5 weeks of no DHCP, got mean %v from %d", mean, n)
+	}
+}
+
+func TestAnomalyModelGap(t *testing.T) {
+	m := NewAnomalyModel()
+	start := time.Date(2016, 1, 3, 0, 0, 0, 0, time.UTC)
+	m.Observe(dosAt("37.59.134.139", 80, start))
+	m.Observe(dosAt("37.59.134.139", 80, start.Add(3*time.Hour)))
+	if _, _, n := m.Expected(eventDoSAttack, start.Add(time.Hour)); n != 1 {
+		t.Errorf("Expected the quiet hour to be learned as zero, got %d samples", n)
+	}
+	m.Observe(dosAt("37.59.134.139", 80, start.Add(48*time.Hour)))
+	if _, _, n := m.Expected(eventDoSAttack, start.Add(24*time.Hour)); n != 0 {
+		t.Errorf("Expected a long gap not to be learned, got %d samples", n)
+	}
+}
+
+func TestAnomalyModelJSON(t *testing.T) {
+	start := time.Date(2016, 1, 3, 0, 0, 0, 0, time.UTC)
+	spike := start.Add((4*7*24 + 30) * time.Hour)
+	logs := anomalyWeeks(start, spike)
+	half := len(logs) / 2
+
+	m := NewAnomalyModel()
+	for _, l := range logs[:half] {
+		m.Observe(l)
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := &AnomalyModel{}
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(restored, m) {
+		t.Fatalf("Restored model differs:\n%+v\n%+v", restored, m)
+	}
+	var want, got []Anomaly
+	for _, l := range logs[half:] {
+		want = append(want, m.Observe(l)...)
+		got = append(got, restored.Observe(l)...)
+	}
+	if len(got) != 1 || !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the restored model to find %+v, got %+v", want, got)
+	}
+
+	if err := json.Unmarshal([]byte(`{"version":2}`), restored); err == nil {
+		t.Error("Expected an unknown version to fail")
+	}
+}
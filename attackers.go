@@ -0,0 +1,266 @@
+package netgearlogs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// AttackerStats summarises the DoS attacks reported from a single source address.
+type AttackerStats struct {
+	Source string
+	Count  int
+	// Attacks are the distinct attack types, such as "SYN/ACK Scan", in order.
+	Attacks []string
+	// Ports are the distinct ports reported with the attacks, in order.
+	Ports     []int
+	FirstSeen time.Time
+	LastSeen  time.Time
+	// MeanInterval is the mean time between consecutive attacks, or 0 for a single one.
+	MeanInterval time.Duration
+}
+
+type attackerStatsJSON struct {
+	Source              string    `json:"source"`
+	Count               int       `json:"count"`
+	Attacks             []string  `json:"attacks"`
+	Ports               []int     `json:"ports"`
+	FirstSeen           time.Time `json:"first_seen"`
+	LastSeen            time.Time `json:"last_seen"`
+	MeanIntervalSeconds float64   `json:"mean_interval_seconds"`
+}
+
+// MarshalJSON writes the stats with snake_case keys and the mean interval in seconds.
+func (s AttackerStats) MarshalJSON() ([]byte, error) {
+	j := attackerStatsJSON{
+		Source:              s.Source,
+		Count:               s.Count,
+		Attacks:             s.Attacks,
+		Ports:               s.Ports,
+		FirstSeen:           s.FirstSeen,
+		LastSeen:            s.LastSeen,
+		MeanIntervalSeconds: s.MeanInterval.Seconds(),
+	}
+	if j.Attacks == nil {
+		j.Attacks = []string{}
+	}
+	if j.Ports == nil {
+		j.Ports = []int{}
+	}
+	return json.Marshal(j)
+}
+
+// AttackerOrder selects how an AttackerReport is sorted.
+type AttackerOrder int
+
+const (
+	// ByCount puts the most frequent attackers first.
+	ByCount AttackerOrder = iota
+	// BySource orders attackers by address.
+	BySource
+	// ByFirstSeen puts the earliest attackers first.
+	ByFirstSeen
+	// ByLastSeen puts the most recently active attackers first.
+	ByLastSeen
+	// ByPorts puts the attackers using the most distinct ports first.
+	ByPorts
+)
+
+// AttackerReport groups the DoS attacks in a time window by source address.
+type AttackerReport struct {
+	// From and To bound the window the report covers; a zero time leaves that end open.
+	From, To time.Time
+	Sources  []AttackerStats
+}
+
+// attackerAcc accumulates the stats for one source.
+type attackerAcc struct {
+	stats   AttackerStats
+	attacks map[string]bool
+	ports   map[int]bool
+}
+
+// ReportAttackers builds an AttackerReport from the DoS entries in logs with
+// from <= Time < to. A zero from or to leaves that end of the window open. The report
+// is sorted ByCount.
+func ReportAttackers(logs []*NetGearLog, from, to time.Time) *AttackerReport {
+	r, _ := ReportAttackersFrom(NewSliceSource(logs), from, to)
+	return r
+}
+
+// ReportAttackersFrom builds an AttackerReport from the entries of src, as
+// ReportAttackers does. Lines that cannot be parsed are skipped; any other error from
+// src is returned along with the report built so far.
+func ReportAttackersFrom(src LogSource, from, to time.Time) (*AttackerReport, error) {
+	accs := make(map[string]*attackerAcc)
+	var err error
+	for {
+		var l *NetGearLog
+		l, err = src.Next()
+		if err == io.EOF {
+			err = nil
+			break
+		}
+		if _, ok := err.(*ParseError); ok {
+			continue
+		}
+		if err != nil {
+			break
+		}
+		kind := l.Kind()
+		if !strings.HasPrefix(kind, eventDoSAttack) {
+			continue
+		}
+		if (!from.IsZero() && l.Time.Before(from)) || (!to.IsZero() && !l.Time.Before(to)) {
+			continue
+		}
+		source := l.SourceIP()
+		a, ok := accs[source]
+		if !ok {
+			a = &attackerAcc{
+				stats:   AttackerStats{Source: source, FirstSeen: l.Time, LastSeen: l.Time},
+				attacks: make(map[string]bool),
+				ports:   make(map[int]bool),
+			}
+			accs[source] = a
+		}
+		a.stats.Count++
+		if l.Time.Before(a.stats.FirstSeen) {
+			a.stats.FirstSeen = l.Time
+		}
+		if l.Time.After(a.stats.LastSeen) {
+			a.stats.LastSeen = l.Time
+		}
+		a.attacks[strings.TrimSpace(strings.TrimPrefix(kind, eventDoSAttack+":"))] = true
+		if port := l.SourcePort(); port != 0 {
+			a.ports[port] = true
+		}
+	}
+
+	r := &AttackerReport{From: from, To: to}
+	for _, a := range accs {
+		s := a.stats
+		for attack := range a.attacks {
+			s.Attacks = append(s.Attacks, attack)
+		}
+		sort.Strings(s.Attacks)
+		for port := range a.ports {
+			s.Ports = append(s.Ports, port)
+		}
+		sort.Ints(s.Ports)
+		if s.Count > 1 {
+			// The mean of the gaps between consecutive attacks is the overall span
+			// divided by the number of gaps.
+			s.MeanInterval = s.LastSeen.Sub(s.FirstSeen) / time.Duration(s.Count-1)
+		}
+		r.Sources = append(r.Sources, s)
+	}
+	r.Sort(ByCount)
+	return r, err
+}
+
+// Sort orders the report's sources. Ties are broken by address.
+func (r *AttackerReport) Sort(order AttackerOrder) {
+	sort.SliceStable(r.Sources, func(i, j int) bool {
+		a, b := r.Sources[i], r.Sources[j]
+		switch order {
+		case ByCount:
+			if a.Count != b.Count {
+				return a.Count > b.Count
+			}
+		case ByFirstSeen:
+			if !a.FirstSeen.Equal(b.FirstSeen) {
+				return a.FirstSeen.Before(b.FirstSeen)
+			}
+		case ByLastSeen:
+			if !a.LastSeen.Equal(b.LastSeen) {
+				return a.LastSeen.After(b.LastSeen)
+			}
+		case ByPorts:
+			if len(a.Ports) != len(b.Ports) {
+				return len(a.Ports) > len(b.Ports)
+			}
+		}
+		return compareAddrs(a.Source, b.Source) < 0
+	})
+}
+
+// compareAddrs orders IP addresses numerically, and anything else after them as text.
+func compareAddrs(a, b string) int {
+	ipa, ipb := net.ParseIP(a), net.ParseIP(b)
+	switch {
+	case ipa != nil && ipb != nil:
+		return bytes.Compare(ipa.To16(), ipb.To16())
+	case ipa != nil:
+		return -1
+	case ipb != nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// maxTablePorts is the number of ports listed per source in a table before the rest
+// are summarised as a count.
+const maxTablePorts = 5
+
+// WriteTable writes the report as an aligned text table.
+func (r *AttackerReport) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "SOURCE\tCOUNT\tATTACKS\tPORTS\tFIRST SEEN\tLAST SEEN\tMEAN INTERVAL")
+	for _, s := range r.Sources {
+		var ports []string
+		for i, p := range s.Ports {
+			if i == maxTablePorts {
+				ports = append(ports, fmt.Sprintf("+%d", len(s.Ports)-maxTablePorts))
+				break
+			}
+			ports = append(ports, strconv.Itoa(p))
+		}
+		interval := "-"
+		if s.Count > 1 {
+			interval = s.MeanInterval.Round(time.Second).String()
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\t%s\n",
+			s.Source, s.Count, strings.Join(s.Attacks, ", "), strings.Join(ports, ","),
+			s.FirstSeen.Format(reportTimeFmt), s.LastSeen.Format(reportTimeFmt), interval)
+	}
+	return tw.Flush()
+}
+
+// reportTimeFmt is the time layout used in text reports.
+const reportTimeFmt = "2006-01-02 15:04:05"
+
+type attackerReportJSON struct {
+	From    *time.Time      `json:"from,omitempty"`
+	To      *time.Time      `json:"to,omitempty"`
+	Sources []AttackerStats `json:"sources"`
+}
+
+// MarshalJSON writes the report as an object holding the window and the sources.
+func (r *AttackerReport) MarshalJSON() ([]byte, error) {
+	j := attackerReportJSON{Sources: r.Sources}
+	if !r.From.IsZero() {
+		j.From = &r.From
+	}
+	if !r.To.IsZero() {
+		j.To = &r.To
+	}
+	if j.Sources == nil {
+		j.Sources = []AttackerStats{}
+	}
+	return json.Marshal(j)
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *AttackerReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
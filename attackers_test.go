@@ -0,0 +1,136 @@
+package netgearlogs
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+var attackerTestLines = []string{
+	"[DoS Attack: SYN/ACK Scan] from source: 37.59.134.139, port 80, Monday, February 22, 2016 18:10:18",
+	"[DoS Attack: ACK Scan] from source: 195.179.119.177, port 80, Monday, February 22, 2016 16:49:18",
+	"[DoS Attack: SYN/ACK Scan] from source: 37.59.134.139, port 80, Monday, February 22, 2016 15:51:43",
+	"[DoS Attack: RST Scan] from source: 195.179.119.177, port 443, Monday, February 22, 2016 14:49:18",
+	"[DoS Attack: ACK Scan] from source: 195.179.119.177, port 80, Monday, February 22, 2016 12:49:18",
+	"[LAN access from remote] from 80.82.79.104:46589 to 192.168.1.9:8080, Monday, February 22, 2016 13:11:37",
+	"[DoS Attack: TCP/UDP Chargen] from source: 185.94.111.1, port 52792, Sunday, February 21, 2016 17:46:56",
+}
+
+func TestReportAttackers(t *testing.T) {
+	r := ReportAttackers(parseLines(attackerTestLines), time.Time{}, time.Time{})
+	if len(r.Sources) != 3 {
+		t.Fatalf("Expected 3 sources, got %d", len(r.Sources))
+	}
+	s := r.Sources[0]
+	if s.Source != "195.179.119.177" || s.Count != 3 {
+		t.Fatalf("Expected 195.179.119.177 with 3 attacks first, got %+v", s)
+	}
+	if strings.Join(s.Attacks, "|") != "ACK Scan|RST Scan" {
+		t.Errorf("Unexpected attack types %v", s.Attacks)
+	}
+	if len(s.Ports) != 2 || s.Ports[0] != 80 || s.Ports[1] != 443 {
+		t.Errorf("Unexpected ports %v", s.Ports)
+	}
+	if s.FirstSeen.Hour() != 12 || s.LastSeen.Hour() != 16 {
+		t.Errorf("Unexpected first and last seen %s, %s", s.FirstSeen, s.LastSeen)
+	}
+	if s.MeanInterval != 2*time.Hour {
+		t.Errorf("Expected a mean interval of 2h, got %s", s.MeanInterval)
+	}
+	if r.Sources[2].Source != "185.94.111.1" || r.Sources[2].MeanInterval != 0 {
+		t.Errorf("Expected the single attack last, got %+v", r.Sources[2])
+	}
+
+	r.Sort(BySource)
+	if r.Sources[0].Source != "37.59.134.139" || r.Sources[2].Source != "195.179.119.177" {
+		t.Errorf("Expected numeric address order, got %s, %s, %s", r.Sources[0].Source, r.Sources[1].Source, r.Sources[2].Source)
+	}
+	r.Sort(ByLastSeen)
+	if r.Sources[0].Source != "37.59.134.139" {
+		t.Errorf("Expected the most recent attacker first, got %s", r.Sources[0].Source)
+	}
+	r.Sort(ByFirstSeen)
+	if r.Sources[0].Source != "185.94.111.1" {
+		t.Errorf("Expected the earliest attacker first, got %s", r.Sources[0].Source)
+	}
+	r.Sort(ByPorts)
+	if r.Sources[0].Source != "195.179.119.177" {
+		t.Errorf("Expected the attacker with most ports first, got %s", r.Sources[0].Source)
+	}
+}
+
+func TestReportAttackersWindow(t *testing.T) {
+	from := time.Date(2016, 2, 22, 13, 0, 0, 0, time.UTC)
+	to := time.Date(2016, 2, 22, 18, 0, 0, 0, time.UTC)
+	r := ReportAttackers(parseLines(attackerTestLines), from, to)
+	if len(r.Sources) != 2 {
+		t.Fatalf("Expected 2 sources in the window, got %d", len(r.Sources))
+	}
+	if s := r.Sources[0]; s.Source != "195.179.119.177" || s.Count != 2 {
+		t.Errorf("Expected 2 attacks from 195.179.119.177 in the window, got %+v", s)
+	}
+	if s := r.Sources[1]; s.Source != "37.59.134.139" || s.Count != 1 {
+		t.Errorf("Expected 1 attack from 37.59.134.139 in the window, got %+v", s)
+	}
+}
+
+func TestReportAttackersLogFile(t *testing.T) {
+	logs := parseLines(readLogLines(t))
+	dos := 0
+	for _, l := range logs {
+		if l != nil && strings.HasPrefix(l.Kind(), "DoS Attack") {
+			dos++
+		}
+	}
+	r := ReportAttackers(logs, time.Time{}, time.Time{})
+	total := 0
+	for _, s := range r.Sources {
+		total += s.Count
+	}
+	if total != dos {
+		t.Errorf("Expected %d attacks across sources, got %d", dos, total)
+	}
+	if r.Sources[0].Count < 10 {
+		t.Errorf("Expected a repeat offender at the top, got %+v", r.Sources[0])
+	}
+}
+
+func TestAttackerReportOutput(t *testing.T) {
+	r := ReportAttackers(parseLines(attackerTestLines), time.Time{}, time.Time{})
+	var buf bytes.Buffer
+	if err := r.WriteTable(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "SOURCE") {
+		t.Fatalf("Unexpected table:\n%s", buf.String())
+	}
+	if !strings.Contains(lines[1], "195.179.119.177") || !strings.Contains(lines[1], "ACK Scan, RST Scan") || !strings.Contains(lines[1], "2h0m0s") {
+		t.Errorf("Unexpected row %q", lines[1])
+	}
+
+	buf.Reset()
+	if err := r.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		From    string `json:"from"`
+		Sources []struct {
+			Source   string  `json:"source"`
+			Count    int     `json:"count"`
+			Ports    []int   `json:"ports"`
+			Interval float64 `json:"mean_interval_seconds"`
+		} `json:"sources"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.From != "" || len(decoded.Sources) != 3 {
+		t.Fatalf("Unexpected JSON %s", buf.String())
+	}
+	if s := decoded.Sources[0]; s.Source != "195.179.119.177" || s.Count != 3 || s.Interval != 7200 || len(s.Ports) != 2 {
+		t.Errorf("Unexpected JSON source %+v", s)
+	}
+}
@@ -0,0 +1,273 @@
+package netgearlogs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Defaults for BlocklistOptions.
+const (
+	DefaultBlocklistThreshold = 3
+	DefaultBlocklistName      = "netgear-blocklist"
+)
+
+// BlocklistOptions control which attackers BuildBlocklist blocks.
+type BlocklistOptions struct {
+	// Threshold is the fewest DoS and LAN access from remote entries a source needs
+	// to be blocked. Defaults to DefaultBlocklistThreshold.
+	Threshold int
+	// Allow lists networks that are never blocked. Private, loopback, link-local and
+	// multicast addresses are never blocked either.
+	Allow []*net.IPNet
+	// MaxAge expires sources not seen within MaxAge of Now. Zero keeps every source.
+	MaxAge time.Duration
+	// Now is the time MaxAge is measured back from. Defaults to the time of the last
+	// entry in the log.
+	Now time.Time
+}
+
+// BlockedSource is an address put on a blocklist, with the activity that put it there.
+type BlockedSource struct {
+	Address             string
+	Count               int
+	FirstSeen, LastSeen time.Time
+}
+
+// Blocklist is the set of attacking addresses to block, aggregated into as few
+// networks as cover exactly those addresses.
+type Blocklist struct {
+	// Generated is the time expiry was measured from.
+	Generated time.Time
+	// Sources are the blocked addresses, ordered by address.
+	Sources []BlockedSource
+	// Networks are the aggregated networks, IPv4 first, ordered by address.
+	Networks []netip.Prefix
+}
+
+// BuildBlocklist collects the sources of the DoS attack and LAN access from remote
+// entries in logs, which may be in any order, and blocks those that pass the
+// threshold, are not allowed and have not expired. If opts is nil the defaults are
+// used.
+func BuildBlocklist(logs []*NetGearLog, opts *BlocklistOptions) *Blocklist {
+	o := BlocklistOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Threshold <= 0 {
+		o.Threshold = DefaultBlocklistThreshold
+	}
+	sources := make(map[string]*BlockedSource)
+	var last time.Time
+	for _, l := range logs {
+		if l == nil {
+			continue
+		}
+		if l.Time.After(last) {
+			last = l.Time
+		}
+		kind := l.Kind()
+		if !strings.HasPrefix(kind, eventDoSAttack) && kind != eventLANAccessFromRemote {
+			continue
+		}
+		ip := l.SourceIP()
+		s, ok := sources[ip]
+		if !ok {
+			s = &BlockedSource{Address: ip, FirstSeen: l.Time, LastSeen: l.Time}
+			sources[ip] = s
+		}
+		s.Count++
+		if l.Time.Before(s.FirstSeen) {
+			s.FirstSeen = l.Time
+		}
+		if l.Time.After(s.LastSeen) {
+			s.LastSeen = l.Time
+		}
+	}
+
+	b := &Blocklist{Generated: o.Now}
+	if b.Generated.IsZero() {
+		b.Generated = last
+	}
+	var prefixes []netip.Prefix
+	for _, s := range sources {
+		addr, err := netip.ParseAddr(s.Address)
+		if err != nil || s.Count < o.Threshold || !blockable(addr, o.Allow) {
+			continue
+		}
+		if o.MaxAge > 0 && b.Generated.Sub(s.LastSeen) > o.MaxAge {
+			continue
+		}
+		addr = addr.Unmap()
+		b.Sources = append(b.Sources, *s)
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	sort.Slice(b.Sources, func(i, j int) bool { return compareAddrs(b.Sources[i].Address, b.Sources[j].Address) < 0 })
+	b.Networks = AggregatePrefixes(prefixes)
+	return b
+}
+
+// blockable reports whether addr is a public address outside allow.
+func blockable(addr netip.Addr, allow []*net.IPNet) bool {
+	addr = addr.Unmap()
+	if addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return false
+	}
+	ip := net.IP(addr.AsSlice())
+	for _, n := range allow {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// AggregatePrefixes returns the fewest prefixes covering exactly the addresses covered
+// by prefixes: prefixes inside others are dropped and adjacent halves are merged. The
+// result holds IPv4 prefixes first, each family ordered by address.
+func AggregatePrefixes(prefixes []netip.Prefix) []netip.Prefix {
+	sorted := make([]netip.Prefix, 0, len(prefixes))
+	for _, p := range prefixes {
+		if p.IsValid() {
+			sorted = append(sorted, p.Masked())
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if c := a.Addr().Compare(b.Addr()); c != 0 {
+			return c < 0
+		}
+		return a.Bits() < b.Bits()
+	})
+	var out []netip.Prefix
+	for _, p := range sorted {
+		if n := len(out); n > 0 && out[n-1].Addr().BitLen() == p.Addr().BitLen() && out[n-1].Overlaps(p) {
+			// Sorted by address, an overlapping prefix lies inside the one before it.
+			continue
+		}
+		out = append(out, p)
+		// Merge the last two while they are the two halves of one prefix.
+		for n := len(out); n >= 2; n = len(out) {
+			a, b := out[n-2], out[n-1]
+			if a.Bits() != b.Bits() || a.Bits() == 0 || a.Addr().BitLen() != b.Addr().BitLen() {
+				break
+			}
+			parent, _ := a.Addr().Prefix(a.Bits() - 1)
+			if parent.Addr() != a.Addr() || !parent.Contains(b.Addr()) {
+				break
+			}
+			out = append(out[:n-2], parent)
+		}
+	}
+	return out
+}
+
+// families splits the networks into IPv4 and IPv6.
+func (b *Blocklist) families() (v4, v6 []netip.Prefix) {
+	for _, p := range b.Networks {
+		if p.Addr().Is4() {
+			v4 = append(v4, p)
+		} else {
+			v6 = append(v6, p)
+		}
+	}
+	return v4, v6
+}
+
+// hostOrPrefix writes a single address without its prefix length.
+func hostOrPrefix(p netip.Prefix) string {
+	if p.IsSingleIP() {
+		return p.Addr().String()
+	}
+	return p.String()
+}
+
+func joinPrefixes(ps []netip.Prefix, sep string) string {
+	s := make([]string, len(ps))
+	for i, p := range ps {
+		s[i] = hostOrPrefix(p)
+	}
+	return strings.Join(s, sep)
+}
+
+func (b *Blocklist) writeHeader(w io.Writer) {
+	fmt.Fprintf(w, "# %d networks from %d attacking sources, generated %s\n", len(b.Networks), len(b.Sources), b.Generated.Format(reportTimeFmt))
+}
+
+// WriteNftables writes an nft script that creates the sets name and name-v6 in the
+// inet table, if they do not exist, and replaces their contents with the blocklist.
+// Load it with nft -f. Empty names default to "netgear" and DefaultBlocklistName.
+func (b *Blocklist) WriteNftables(w io.Writer, table, name string) error {
+	if table == "" {
+		table = "netgear"
+	}
+	if name == "" {
+		name = DefaultBlocklistName
+	}
+	v4, v6 := b.families()
+	bw := bufio.NewWriter(w)
+	b.writeHeader(bw)
+	fmt.Fprintf(bw, "add table inet %s\n", table)
+	for _, f := range []struct {
+		set, typ string
+		nets     []netip.Prefix
+	}{{name, "ipv4_addr", v4}, {name + "-v6", "ipv6_addr", v6}} {
+		fmt.Fprintf(bw, "add set inet %s %s { type %s; flags interval; }\n", table, f.set, f.typ)
+		fmt.Fprintf(bw, "flush set inet %s %s\n", table, f.set)
+		if len(f.nets) > 0 {
+			fmt.Fprintf(bw, "add element inet %s %s { %s }\n", table, f.set, joinPrefixes(f.nets, ", "))
+		}
+	}
+	return bw.Flush()
+}
+
+// WriteIPSet writes an ipset restore file that creates the hash:net sets name and
+// name-v6, if they do not exist, and replaces their contents with the blocklist. Load
+// it with ipset restore, and match the sets from iptables and ip6tables with -m set.
+// An empty name defaults to DefaultBlocklistName.
+func (b *Blocklist) WriteIPSet(w io.Writer, name string) error {
+	if name == "" {
+		name = DefaultBlocklistName
+	}
+	v4, v6 := b.families()
+	bw := bufio.NewWriter(w)
+	b.writeHeader(bw)
+	for _, f := range []struct {
+		set, family string
+		nets        []netip.Prefix
+	}{{name, "inet", v4}, {name + "-v6", "inet6", v6}} {
+		fmt.Fprintf(bw, "create %s hash:net family %s -exist\n", f.set, f.family)
+		fmt.Fprintf(bw, "flush %s\n", f.set)
+		for _, p := range f.nets {
+			fmt.Fprintf(bw, "add %s %s\n", f.set, hostOrPrefix(p))
+		}
+	}
+	return bw.Flush()
+}
+
+// WritePF writes the blocklist as a pf table file, one address or network per line.
+// Load it with pfctl -t <table> -T replace -f <file>, or from pf.conf with
+// table <table> persist file "<file>".
+func (b *Blocklist) WritePF(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	b.writeHeader(bw)
+	for _, p := range b.Networks {
+		fmt.Fprintln(bw, hostOrPrefix(p))
+	}
+	return bw.Flush()
+}
+
+// WriteCIDRs writes the blocklist as a plain list of networks in CIDR notation, one
+// per line, with single addresses as /32 or /128.
+func (b *Blocklist) WriteCIDRs(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, p := range b.Networks {
+		fmt.Fprintln(bw, p.String())
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,115 @@
+package netgearlogs
+
+import (
+	"bytes"
+	"net"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAggregatePrefixes(t *testing.T) {
+	var in []netip.Prefix
+	for _, s := range []string{
+		"10.0.0.3/32", "10.0.0.0/32", "10.0.0.1/32", "10.0.0.2/32", "10.0.0.4/32",
+		"192.0.2.0/25", "192.0.2.128/25", "192.0.2.7/32",
+		"2001:db8::1/128", "2001:db8::/128",
+	} {
+		in = append(in, netip.MustParsePrefix(s))
+	}
+	var got []string
+	for _, p := range AggregatePrefixes(in) {
+		got = append(got, p.String())
+	}
+	want := []string{"10.0.0.0/30", "10.0.0.4/32", "192.0.2.0/24", "2001:db8::/127"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestBuildBlocklist(t *testing.T) {
+	base := time.Date(2016, 2, 22, 12, 0, 0, 0, time.UTC)
+	var logs []*NetGearLog
+	for i := 0; i < 3; i++ {
+		for _, src := range []string{"80.82.64.0", "80.82.64.1", "80.82.64.2", "80.82.64.3", "37.59.134.139", "192.168.1.6", "8.8.8.8"} {
+			logs = append(logs, dosAt(src, 80, base.Add(time.Duration(i)*time.Minute)))
+		}
+	}
+	// Below the threshold.
+	logs = append(logs, dosAt("94.23.182.44", 80, base), dosAt("94.23.182.44", 80, base))
+	// Seen long before the rest.
+	for i := 0; i < 3; i++ {
+		logs = append(logs, dosAt("167.114.119.146", 80, base.Add(-48*time.Hour)))
+	}
+	logs = append(logs, &NetGearLog{Time: base.Add(time.Hour), EventType: eventTimeSyncNTP})
+
+	_, google, _ := net.ParseCIDR("8.8.8.0/24")
+	b := BuildBlocklist(logs, &BlocklistOptions{Allow: []*net.IPNet{google}, MaxAge: 24 * time.Hour})
+	if !b.Generated.Equal(base.Add(time.Hour)) {
+		t.Errorf("Expected the blocklist generated at the last entry, got %s", b.Generated)
+	}
+	if len(b.Sources) != 5 || b.Sources[0].Address != "37.59.134.139" || b.Sources[0].Count != 3 {
+		t.Fatalf("Unexpected sources %+v", b.Sources)
+	}
+	if len(b.Networks) != 2 || b.Networks[0].String() != "37.59.134.139/32" || b.Networks[1].String() != "80.82.64.0/30" {
+		t.Errorf("Unexpected networks %v", b.Networks)
+	}
+
+	if all := BuildBlocklist(logs, nil); len(all.Sources) != 7 {
+		t.Errorf("Expected 7 sources without allowlist or expiry, got %+v", all.Sources)
+	}
+}
+
+func TestBlocklistWriters(t *testing.T) {
+	b := &Blocklist{
+		Generated: time.Date(2016, 2, 22, 12, 0, 0, 0, time.UTC),
+		Sources:   make([]BlockedSource, 5),
+		Networks: []netip.Prefix{
+			netip.MustParsePrefix("37.59.134.139/32"),
+			netip.MustParsePrefix("80.82.64.0/30"),
+			netip.MustParsePrefix("2001:db8::/127"),
+		},
+	}
+	header := "# 3 networks from 5 attacking sources, generated 2016-02-22 12:00:00\n"
+	for _, tc := range []struct {
+		name  string
+		write func(*bytes.Buffer) error
+		want  string
+	}{
+		{"nftables", func(w *bytes.Buffer) error { return b.WriteNftables(w, "", "") }, header +
+			"add table inet netgear\n" +
+			"add set inet netgear netgear-blocklist { type ipv4_addr; flags interval; }\n" +
+			"flush set inet netgear netgear-blocklist\n" +
+			"add element inet netgear netgear-blocklist { 37.59.134.139, 80.82.64.0/30 }\n" +
+			"add set inet netgear netgear-blocklist-v6 { type ipv6_addr; flags interval; }\n" +
+			"flush set inet netgear netgear-blocklist-v6\n" +
+			"add element inet netgear netgear-blocklist-v6 { 2001:db8::/127 }\n"},
+		{"ipset", func(w *bytes.Buffer) error { return b.WriteIPSet(w, "attackers") }, header +
+			"create attackers hash:net family inet -exist\n" +
+			"flush attackers\n" +
+			"add attackers 37.59.134.139\n" +
+			"add attackers 80.82.64.0/30\n" +
+			"create attackers-v6 hash:net family inet6 -exist\n" +
+			"flush attackers-v6\n" +
+			"add attackers-v6 2001:db8::/127\n"},
+		{"pf", func(w *bytes.Buffer) error { return b.WritePF(w) }, header +
+			"37.59.134.139\n80.82.64.0/30\n2001:db8::/127\n"},
+		{"cidr", func(w *bytes.Buffer) error { return b.WriteCIDRs(w) },
+			"37.59.134.139/32\n80.82.64.0/30\n2001:db8::/127\n"},
+	} {
+		var buf bytes.Buffer
+		if err := tc.write(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tc.want {
+			t.Errorf("%s: expected\n%s\ngot\n%s", tc.name, tc.want, buf.String())
+		}
+	}
+
+	var buf bytes.Buffer
+	(&Blocklist{}).WriteNftables(&buf, "fw", "bad")
+	if bytes.Contains(buf.Bytes(), []byte("add element")) {
+		t.Errorf("Expected no elements for an empty blocklist, got\n%s", buf.String())
+	}
+}
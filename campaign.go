@@ -0,0 +1,243 @@
+package netgearlogs
+
+import (
+	"container/list"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CampaignKind classifies a Campaign.
+type CampaignKind string
+
+// The router only logs attacks on itself, so campaigns are told apart by what varies
+// on the attacking side.
+const (
+	// HorizontalScan is the same few ports probed from many addresses in one network.
+	HorizontalScan CampaignKind = "horizontal-scan"
+	// VerticalScan is many different ports probed from one network.
+	VerticalScan CampaignKind = "vertical-scan"
+	// RepeatedProbe is the same source probing the same few ports again and again.
+	RepeatedProbe CampaignKind = "repeated-probe"
+)
+
+// Campaign is a run of DoS attacks from one source network.
+type Campaign struct {
+	Kind CampaignKind
+	// Network is the /24 (or /64 for IPv6) the attacks came from, and Sources the
+	// addresses in it that took part.
+	Network string
+	Sources []string
+	// Attacks are the attack types seen, and Ports the distinct ports reported.
+	Attacks    []string
+	Ports      []int
+	Start, End time.Time
+	Events     int
+}
+
+// Defaults for CampaignOptions.
+const (
+	DefaultCampaignWindow            = 30 * time.Minute
+	DefaultCampaignMinEvents         = 3
+	DefaultCampaignVerticalPorts     = 5
+	DefaultCampaignHorizontalSources = 3
+	DefaultCampaignMaxTracked        = 4096
+	// maxCampaignValues caps the distinct ports, sources and attack types kept per
+	// campaign.
+	maxCampaignValues = 256
+)
+
+// CampaignOptions configure a CampaignCorrelator. Zero fields take the defaults.
+type CampaignOptions struct {
+	// Window is how long a network may go quiet before its campaign is over.
+	Window time.Duration
+	// MinEvents is the fewest attacks that make a campaign; shorter runs are dropped.
+	MinEvents int
+	// VerticalPorts is the number of distinct ports that makes a VerticalScan, and
+	// HorizontalSources the number of distinct sources that makes a HorizontalScan.
+	VerticalPorts     int
+	HorizontalSources int
+	// MaxTracked is the most networks followed at once. When it is exceeded the least
+	// recently active campaign is closed early.
+	MaxTracked int
+}
+
+// CampaignCorrelator groups DoS attacks into campaigns as they arrive. Feed it entries
+// in time order with Observe; it returns each campaign once the network behind it has
+// been quiet for the window. Memory is bounded by MaxTracked networks, each keeping at
+// most a fixed number of distinct ports and sources.
+type CampaignCorrelator struct {
+	opts CampaignOptions
+	// active holds the open campaigns, least recently active first.
+	active  *list.List
+	byGroup map[string]*list.Element
+}
+
+type campaignState struct {
+	Campaign
+	last    time.Time
+	sources map[string]bool
+	ports   map[int]bool
+	attacks map[string]bool
+}
+
+// NewCampaignCorrelator returns a correlator with the given options, or the defaults
+// if opts is nil.
+func NewCampaignCorrelator(opts *CampaignOptions) *CampaignCorrelator {
+	o := CampaignOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Window <= 0 {
+		o.Window = DefaultCampaignWindow
+	}
+	if o.MinEvents <= 0 {
+		o.MinEvents = DefaultCampaignMinEvents
+	}
+	if o.VerticalPorts <= 0 {
+		o.VerticalPorts = DefaultCampaignVerticalPorts
+	}
+	if o.HorizontalSources <= 0 {
+		o.HorizontalSources = DefaultCampaignHorizontalSources
+	}
+	if o.MaxTracked <= 0 {
+		o.MaxTracked = DefaultCampaignMaxTracked
+	}
+	return &CampaignCorrelator{opts: o, active: list.New(), byGroup: make(map[string]*list.Element)}
+}
+
+// campaignNetwork returns the /24 or /64 holding ip, or ip itself if it is not an
+// address.
+func campaignNetwork(ip string) string {
+	parsed := net.ParseIP(ip)
+	switch {
+	case parsed == nil:
+		return ip
+	case parsed.To4() != nil:
+		return (&net.IPNet{IP: parsed.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: parsed.Mask(net.CIDRMask(64, 128)), Mask: net.CIDRMask(64, 128)}).String()
+}
+
+// Observe adds l if it is a DoS attack, and returns any campaigns that ended before it.
+func (c *CampaignCorrelator) Observe(l *NetGearLog) []Campaign {
+	ended := c.expire(l.Time)
+	kind := l.Kind()
+	if !strings.HasPrefix(kind, eventDoSAttack) {
+		return ended
+	}
+	source := l.SourceIP()
+	group := campaignNetwork(source)
+	e, ok := c.byGroup[group]
+	if !ok {
+		if c.active.Len() >= c.opts.MaxTracked {
+			if done, ok := c.close(c.active.Front()); ok {
+				ended = append(ended, done)
+			}
+		}
+		s := &campaignState{
+			Campaign: Campaign{Network: group, Start: l.Time},
+			sources:  make(map[string]bool),
+			ports:    make(map[int]bool),
+			attacks:  make(map[string]bool),
+		}
+		e = c.active.PushBack(s)
+		c.byGroup[group] = e
+	}
+	c.active.MoveToBack(e)
+	s := e.Value.(*campaignState)
+	s.Events++
+	s.last = l.Time
+	if l.Time.After(s.End) {
+		s.End = l.Time
+	}
+	if len(s.sources) < maxCampaignValues {
+		s.sources[source] = true
+	}
+	if port := l.SourcePort(); port != 0 && len(s.ports) < maxCampaignValues {
+		s.ports[port] = true
+	}
+	if len(s.attacks) < maxCampaignValues {
+		s.attacks[strings.TrimSpace(strings.TrimPrefix(kind, eventDoSAttack+":"))] = true
+	}
+	return ended
+}
+
+// expire closes the campaigns that have been quiet for longer than the window at now.
+func (c *CampaignCorrelator) expire(now time.Time) []Campaign {
+	var ended []Campaign
+	for e := c.active.Front(); e != nil; e = c.active.Front() {
+		if now.Sub(e.Value.(*campaignState).last) <= c.opts.Window {
+			break
+		}
+		if done, ok := c.close(e); ok {
+			ended = append(ended, done)
+		}
+	}
+	return ended
+}
+
+// close stops tracking a campaign, returning it if it had enough events.
+func (c *CampaignCorrelator) close(e *list.Element) (Campaign, bool) {
+	s := c.active.Remove(e).(*campaignState)
+	delete(c.byGroup, s.Network)
+	if s.Events < c.opts.MinEvents {
+		return Campaign{}, false
+	}
+	camp := s.Campaign
+	for src := range s.sources {
+		camp.Sources = append(camp.Sources, src)
+	}
+	sort.Slice(camp.Sources, func(i, j int) bool { return compareAddrs(camp.Sources[i], camp.Sources[j]) < 0 })
+	for p := range s.ports {
+		camp.Ports = append(camp.Ports, p)
+	}
+	sort.Ints(camp.Ports)
+	for a := range s.attacks {
+		camp.Attacks = append(camp.Attacks, a)
+	}
+	sort.Strings(camp.Attacks)
+	switch {
+	case len(camp.Ports) >= c.opts.VerticalPorts:
+		camp.Kind = VerticalScan
+	case len(camp.Sources) >= c.opts.HorizontalSources:
+		camp.Kind = HorizontalScan
+	default:
+		camp.Kind = RepeatedProbe
+	}
+	return camp, true
+}
+
+// Flush closes every open campaign and returns those with enough events, such as at
+// the end of a log.
+func (c *CampaignCorrelator) Flush() []Campaign {
+	var ended []Campaign
+	for e := c.active.Front(); e != nil; e = c.active.Front() {
+		if done, ok := c.close(e); ok {
+			ended = append(ended, done)
+		}
+	}
+	return ended
+}
+
+// CorrelateCampaigns returns the campaigns among logs, which may be in ascending order
+// or newest first, ordered by start time. Campaigns starting in the same second are in
+// the order they started.
+func CorrelateCampaigns(logs []*NetGearLog, opts *CampaignOptions) []Campaign {
+	sorted := make([]*NetGearLog, 0, len(logs))
+	for _, l := range logs {
+		if l != nil {
+			sorted = append(sorted, l)
+		}
+	}
+	sortOccurrences(sorted)
+	c := NewCampaignCorrelator(opts)
+	var campaigns []Campaign
+	for _, l := range sorted {
+		campaigns = append(campaigns, c.Observe(l)...)
+	}
+	campaigns = append(campaigns, c.Flush()...)
+	sort.SliceStable(campaigns, func(i, j int) bool { return campaigns[i].Start.Before(campaigns[j].Start) })
+	return campaigns
+}
@@ -0,0 +1,96 @@
+package netgearlogs
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func dosAt(source string, port int, at time.Time) *NetGearLog {
+	return &NetGearLog{Time: at, EventType: eventDoSAttackSynAckScan, FromSource: source, Port: port}
+}
+
+func TestCampaignCorrelator(t *testing.T) {
+	base := time.Date(2016, 2, 22, 12, 0, 0, 0, time.UTC)
+	min := func(n int) time.Time { return base.Add(time.Duration(n) * time.Minute) }
+	var logs []*NetGearLog
+	// A vertical scan from one address.
+	for i := 0; i < 6; i++ {
+		logs = append(logs, dosAt("37.59.134.139", 20+i, min(i)))
+	}
+	// A horizontal scan across a /24, on one port.
+	for i := 0; i < 4; i++ {
+		logs = append(logs, dosAt("80.82.64."+strconv.Itoa(i+1), 443, min(2*i)))
+	}
+	// A repeated probe, split by a quiet spell into two runs, the second too short.
+	for _, m := range []int{0, 10, 20, 100, 110} {
+		logs = append(logs, dosAt("195.179.119.177", 80, min(m)))
+	}
+	logs = append(logs, &NetGearLog{Time: min(300), EventType: eventTimeSyncNTP})
+
+	c := NewCampaignCorrelator(nil)
+	SortLogs(logs)
+	var campaigns []Campaign
+	for _, l := range logs {
+		campaigns = append(campaigns, c.Observe(l)...)
+	}
+	if len(campaigns) != 3 {
+		t.Fatalf("Expected 3 campaigns to have ended, got %+v", campaigns)
+	}
+	if len(c.Flush()) != 0 {
+		t.Error("Expected no campaigns left open")
+	}
+	kinds := make(map[string]Campaign)
+	for _, camp := range campaigns {
+		kinds[camp.Network] = camp
+	}
+	if v := kinds["37.59.134.0/24"]; v.Kind != VerticalScan || v.Events != 6 || len(v.Ports) != 6 || !v.End.Equal(min(5)) {
+		t.Errorf("Unexpected vertical scan %+v", v)
+	}
+	if h := kinds["80.82.64.0/24"]; h.Kind != HorizontalScan || len(h.Sources) != 4 || len(h.Ports) != 1 {
+		t.Errorf("Unexpected horizontal scan %+v", h)
+	}
+	if p := kinds["195.179.119.0/24"]; p.Kind != RepeatedProbe || p.Events != 3 || !p.Start.Equal(base) || !p.End.Equal(min(20)) {
+		t.Errorf("Unexpected repeated probe %+v", p)
+	}
+	if p := kinds["195.179.119.0/24"]; len(p.Attacks) != 1 || p.Attacks[0] != "SYN/ACK Scan" {
+		t.Errorf("Unexpected attack types %v", p.Attacks)
+	}
+}
+
+func TestCampaignCorrelatorBounded(t *testing.T) {
+	base := time.Date(2016, 2, 22, 12, 0, 0, 0, time.UTC)
+	c := NewCampaignCorrelator(&CampaignOptions{MaxTracked: 2, MinEvents: 1})
+	var ended []Campaign
+	for i, src := range []string{"1.1.1.1", "2.2.2.2", "1.1.1.2", "3.3.3.3"} {
+		ended = append(ended, c.Observe(dosAt(src, 80, base.Add(time.Duration(i)*time.Second)))...)
+	}
+	if c.active.Len() != 2 {
+		t.Errorf("Expected 2 tracked networks, got %d", c.active.Len())
+	}
+	if len(ended) != 1 || ended[0].Network != "2.2.2.0/24" {
+		t.Errorf("Expected the least recently active network to be closed, got %+v", ended)
+	}
+}
+
+func TestCorrelateCampaignsSameSecond(t *testing.T) {
+	base := time.Date(2016, 2, 22, 12, 0, 0, 0, time.UTC)
+	// Newest first, as exported: 1.1.1.1 attacked first, then 2.2.2.2 in the same second.
+	logs := []*NetGearLog{dosAt("2.2.2.2", 80, base), dosAt("1.1.1.1", 80, base)}
+	campaigns := CorrelateCampaigns(logs, &CampaignOptions{MinEvents: 1})
+	if len(campaigns) != 2 || campaigns[0].Network != "1.1.1.0/24" || campaigns[1].Network != "2.2.2.0/24" {
+		t.Errorf("Expected campaigns in the order they started, got %+v", campaigns)
+	}
+}
+
+func TestCorrelateCampaignsLogFile(t *testing.T) {
+	campaigns := CorrelateCampaigns(parseLines(readLogLines(t)), nil)
+	if len(campaigns) == 0 {
+		t.Fatal("Expected campaigns in log.txt")
+	}
+	for i := 1; i < len(campaigns); i++ {
+		if campaigns[i].Start.Before(campaigns[i-1].Start) {
+			t.Fatal("Expected campaigns in start order")
+		}
+	}
+}
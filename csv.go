@@ -0,0 +1,182 @@
+package netgearlogs
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Columns understood by CSVWriter.
+const (
+	CSVColumnTime   = "time"
+	CSVColumnKind   = "kind"
+	CSVColumnEvent  = "event"
+	CSVColumnSource = "source"
+	CSVColumnPort   = "port"
+	CSVColumnDest   = "dest"
+	CSVColumnMAC    = "mac"
+	CSVColumnFile   = "file"
+	CSVColumnRaw    = "raw"
+)
+
+// DefaultCSVColumns are the columns written when CSVWriter.Columns is empty.
+var DefaultCSVColumns = []string{CSVColumnTime, CSVColumnKind, CSVColumnSource, CSVColumnPort, CSVColumnDest, CSVColumnMAC}
+
+// CSVWriter writes entries as CSV or TSV with a header row. Its fields may be changed
+// until the first entry is written.
+//
+// Cells that a spreadsheet would treat as a formula, those starting with '=', '+',
+// '-', '@', a tab or a carriage return, are prefixed with a single quote so that a
+// crafted host name cannot inject one.
+type CSVWriter struct {
+	// Columns selects and orders the columns; see the CSVColumn constants.
+	Columns []string
+	// Comma is the field delimiter: ',' for CSV or '\t' for TSV.
+	Comma rune
+	// TimeFormat is the layout used for the time column. Defaults to time.RFC3339.
+	TimeFormat string
+	// Location, if set, is the time zone times are converted to before formatting.
+	Location *time.Location
+
+	w       io.Writer
+	cw      *csv.Writer
+	columns []string
+}
+
+// NewCSVWriter returns a CSVWriter writing comma-separated values to w.
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{w: w, Comma: ',', TimeFormat: time.RFC3339}
+}
+
+// NewTSVWriter returns a CSVWriter writing tab-separated values to w.
+func NewTSVWriter(w io.Writer) *CSVWriter {
+	cw := NewCSVWriter(w)
+	cw.Comma = '\t'
+	return cw
+}
+
+// Write writes a single entry, preceded by the header row if it is the first.
+func (w *CSVWriter) Write(l *NetGearLog) error {
+	if w.cw == nil {
+		if err := w.writeHeader(); err != nil {
+			return err
+		}
+	}
+	record := make([]string, len(w.columns))
+	for i, c := range w.columns {
+		record[i] = escapeFormula(w.cell(l, c))
+	}
+	return w.cw.Write(record)
+}
+
+// WriteAll writes every non-nil entry in logs and flushes the output.
+func (w *CSVWriter) WriteAll(logs []*NetGearLog) error {
+	return w.WriteSource(NewSliceSource(logs))
+}
+
+// WriteSource writes every entry from src and flushes the output. Entries that fail
+// to parse are skipped; any other error stops the write.
+func (w *CSVWriter) WriteSource(src LogSource) error {
+	for {
+		l, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+		if _, ok := err.(*ParseError); ok {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := w.Write(l); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// Flush writes the header if nothing has been written yet, then flushes any
+// buffered data to the underlying writer.
+func (w *CSVWriter) Flush() error {
+	if w.cw == nil {
+		if err := w.writeHeader(); err != nil {
+			return err
+		}
+	}
+	w.cw.Flush()
+	return w.cw.Error()
+}
+
+func (w *CSVWriter) writeHeader() error {
+	w.columns = w.Columns
+	if len(w.columns) == 0 {
+		w.columns = DefaultCSVColumns
+	}
+	for _, c := range w.columns {
+		switch c {
+		case CSVColumnTime, CSVColumnKind, CSVColumnEvent, CSVColumnSource, CSVColumnPort,
+			CSVColumnDest, CSVColumnMAC, CSVColumnFile, CSVColumnRaw:
+		default:
+			return fmt.Errorf("unknown CSV column %q", c)
+		}
+	}
+	w.cw = csv.NewWriter(w.w)
+	w.cw.Comma = w.Comma
+	return w.cw.Write(w.columns)
+}
+
+func (w *CSVWriter) cell(l *NetGearLog, column string) string {
+	switch column {
+	case CSVColumnTime:
+		t := l.Time
+		if w.Location != nil {
+			t = t.In(w.Location)
+		}
+		format := w.TimeFormat
+		if format == "" {
+			format = time.RFC3339
+		}
+		return t.Format(format)
+	case CSVColumnKind:
+		return l.Kind()
+	case CSVColumnEvent:
+		return l.EventType
+	case CSVColumnSource:
+		return l.SourceIP()
+	case CSVColumnPort:
+		if p := l.SourcePort(); p != 0 {
+			return strconv.Itoa(p)
+		}
+		return ""
+	case CSVColumnDest:
+		return l.ToDest
+	case CSVColumnMAC:
+		return l.ToMACAddress
+	case CSVColumnFile:
+		return l.File
+	case CSVColumnRaw:
+		return l.Raw
+	}
+	return ""
+}
+
+// escapeFormula neutralises cells that spreadsheets would evaluate as formulas.
+// Spreadsheets skip leading spaces, so the first other character is checked.
+func escapeFormula(s string) string {
+	if s == "" {
+		return s
+	}
+	switch s[0] {
+	case '\t', '\r':
+		return "'" + s
+	}
+	t := strings.TrimLeftFunc(s, unicode.IsSpace)
+	if t != "" && strings.IndexByte("=+-@", t[0]) >= 0 {
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,113 @@
+package netgearlogs
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCSVWriterDefaultColumns(t *testing.T) {
+	lines := []string{
+		"[DoS Attack: SYN/ACK Scan] from source: 37.59.134.139, port 80, Monday, February 22, 2016 18:10:18",
+		"[LAN access from remote] from 80.82.79.104:46589 to 192.168.1.9:8080, Monday, February 22, 2016 13:11:37",
+		"[DHCP IP: 192.168.1.6] to MAC address b4:b6:76:bf:19:8b, Monday, February 22, 2016 18:31:22",
+	}
+	var logs []*NetGearLog
+	for _, line := range lines {
+		l, err := ParseNetGearLogLine(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logs = append(logs, l)
+	}
+	var buf bytes.Buffer
+	if err := NewCSVWriter(&buf).WriteAll(logs); err != nil {
+		t.Fatal(err)
+	}
+	expected := "time,kind,source,port,dest,mac\n" +
+		"2016-02-22T18:10:18Z,DoS Attack: SYN/ACK Scan,37.59.134.139,80,,\n" +
+		"2016-02-22T13:11:37Z,LAN access from remote,80.82.79.104,46589,192.168.1.9:8080,\n" +
+		"2016-02-22T18:31:22Z,DHCP IP,192.168.1.6,,,b4:b6:76:bf:19:8b\n"
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, buf.String())
+	}
+}
+
+func TestTSVWriterColumnsAndTimeZone(t *testing.T) {
+	l, err := ParseNetGearLogLine("[admin login] from source 192.168.1.6, Tuesday, February 23, 2016 19:06:07")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w := NewTSVWriter(&buf)
+	w.Columns = []string{CSVColumnTime, CSVColumnEvent, CSVColumnRaw}
+	w.TimeFormat = "2006-01-02 15:04"
+	w.Location = time.FixedZone("CST", -6*60*60)
+	if err := w.WriteAll([]*NetGearLog{l}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "time\tevent\traw\n2016-02-23 13:06\tadmin login\t" + l.Raw + "\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestCSVWriterEscapesFormulas(t *testing.T) {
+	l, err := ParseNetGearLogLine("[Site allowed: =HYPERLINK(\"http://evil\")] from source 192.168.1.21, Wednesday, May 16,2018 08:59:58")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w := NewCSVWriter(&buf)
+	w.Columns = []string{CSVColumnDest, CSVColumnSource}
+	if err := w.WriteAll([]*NetGearLog{l, {FromSource: "-1+2", ToDest: "+cmd"}, {ToDest: "@SUM(A1)"}, {ToDest: " =HYPERLINK(\"http://evil\")", FromSource: "\u00a0-1"}}); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]string{
+		{"dest", "source"},
+		{"'=HYPERLINK(\"http://evil\")", "192.168.1.21"},
+		{"'+cmd", "'-1+2"},
+		{"'@SUM(A1)", ""},
+		{"' =HYPERLINK(\"http://evil\")", "'\u00a0-1"},
+	}
+	for i := range expected {
+		for j := range expected[i] {
+			if records[i][j] != expected[i][j] {
+				t.Errorf("Row %d column %d: expected %q, got %q", i, j, expected[i][j], records[i][j])
+			}
+		}
+	}
+}
+
+func TestCSVWriterUnknownColumn(t *testing.T) {
+	w := NewCSVWriter(&bytes.Buffer{})
+	w.Columns = []string{"bogus"}
+	if err := w.Write(&NetGearLog{}); err == nil {
+		t.Error("Expected an error for an unknown column")
+	}
+}
+
+func TestCSVWriterLogFile(t *testing.T) {
+	f, err := os.Open("log.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var buf bytes.Buffer
+	if err := NewCSVWriter(&buf).WriteSource(NewLogReader(f)); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) < 1500 {
+		t.Errorf("Expected a row per entry, got %d", len(records))
+	}
+}
@@ -0,0 +1,216 @@
+package netgearlogs
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DedupLogs combines several exports of the same router log, which usually overlap,
+// into a single ascending stream in which each event appears once.
+//
+// Exports are aligned on the entries they have in common, as a diff would align two
+// versions of a file: entries that two exports both hold, in the same order, are kept
+// once, and every entry outside the overlap is kept. A DHCP lease renewed twice in one
+// second therefore yields two entries, even when each renewal is only in one of the
+// exports. Exports are aligned in the order of their first entries, and may be in
+// either newest-first router order or ascending order.
+func DedupLogs(exports ...[]*NetGearLog) []*NetGearLog {
+	srcs := make([]LogSource, len(exports))
+	for i, logs := range exports {
+		cp := make([]*NetGearLog, len(logs))
+		copy(cp, logs)
+		sortOccurrences(cp)
+		srcs[i] = NewSliceSource(cp)
+	}
+	var deduped []*NetGearLog
+	d := NewDedupSource(srcs...)
+	for {
+		log, err := d.Next()
+		if err != nil {
+			break
+		}
+		deduped = append(deduped, log)
+	}
+	return deduped
+}
+
+// NewDedupSource returns a LogSource that merges several overlapping exports, each in
+// ascending time order, and drops the entries they have in common, as described for
+// DedupLogs. Entries only match within the same second, so only the entries of a
+// single second are held in memory at a time. Errors from the underlying sources are
+// passed through as with NewMergeSource.
+func NewDedupSource(sources ...LogSource) LogSource {
+	d := &dedupSource{
+		src:   make(map[*NetGearLog]sourcedLog),
+		first: make([]time.Time, len(sources)),
+	}
+	tagged := make([]LogSource, len(sources))
+	for i, s := range sources {
+		tagged[i] = &indexedSource{src: s, idx: i, d: d}
+	}
+	d.m = NewMergeSource(tagged...)
+	return d
+}
+
+type dedupSource struct {
+	m     LogSource
+	src   map[*NetGearLog]sourcedLog // origin of each entry read but not yet queued
+	first []time.Time                // time of the first entry read from each source
+	group []*NetGearLog              // entries of the second currently being read
+	out   []*NetGearLog
+	done  bool
+}
+
+func (d *dedupSource) Next() (*NetGearLog, error) {
+	for len(d.out) == 0 {
+		if d.done {
+			return nil, io.EOF
+		}
+		if err := d.fillSecond(); err != nil {
+			return nil, err
+		}
+	}
+	log := d.out[0]
+	d.out = d.out[1:]
+	return log, nil
+}
+
+// fillSecond reads from the merge until a second is complete and queues its
+// deduplicated entries.
+func (d *dedupSource) fillSecond() error {
+	for {
+		log, err := d.m.Next()
+		if err == io.EOF {
+			d.done = true
+			d.queue(d.group)
+			d.group = nil
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(d.group) > 0 && log.Time.Unix() != d.group[0].Time.Unix() {
+			d.queue(d.group)
+			d.group = []*NetGearLog{log}
+			return nil
+		}
+		d.group = append(d.group, log)
+	}
+}
+
+// queue aligns the entries of one second from each source, in the order the sources
+// began, and queues the result.
+func (d *dedupSource) queue(group []*NetGearLog) {
+	bySource := make([][]*NetGearLog, len(d.first))
+	var order []int
+	for _, l := range group {
+		s := d.src[l]
+		if bySource[s.idx] == nil {
+			order = append(order, s.idx)
+		}
+		bySource[s.idx] = append(bySource[s.idx], s.log)
+		delete(d.src, l)
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := order[i], order[j]
+		if !d.first[a].Equal(d.first[b]) {
+			return d.first[a].Before(d.first[b])
+		}
+		return a < b
+	})
+	var aligned []*NetGearLog
+	for _, i := range order {
+		aligned = alignLogs(aligned, bySource[i])
+	}
+	d.out = append(d.out, aligned...)
+}
+
+// alignLogs merges two runs of entries on their longest common subsequence. Common
+// entries are taken from a; where the alignment is ambiguous, entries of a come first.
+func alignLogs(a, b []*NetGearLog) []*NetGearLog {
+	if len(a) == 0 || len(b) == 0 {
+		return append(a, b...)
+	}
+	ka, kb := make([]string, len(a)), make([]string, len(b))
+	for i, l := range a {
+		ka[i] = dedupKey(l)
+	}
+	for j, l := range b {
+		kb[j] = dedupKey(l)
+	}
+	// lcs[i][j] is the length of the longest common subsequence of ka[i:] and kb[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case ka[i] == kb[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	merged := make([]*NetGearLog, 0, len(a)+len(b)-lcs[0][0])
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case ka[i] == kb[j]:
+			merged = append(merged, a[i])
+			i, j = i+1, j+1
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			merged = append(merged, a[i])
+			i++
+		default:
+			merged = append(merged, b[j])
+			j++
+		}
+	}
+	merged = append(merged, a[i:]...)
+	return append(merged, b[j:]...)
+}
+
+// dedupKey identifies an event independently of the export it was read from.
+func dedupKey(l *NetGearLog) string {
+	return strings.Join([]string{
+		l.Time.UTC().Format("20060102150405"),
+		strings.ToLower(strings.TrimSpace(l.EventType)),
+		strings.ToLower(strings.TrimSpace(l.FromSource)),
+		strings.ToLower(strings.TrimSpace(l.ToDest)),
+		strings.ToLower(strings.TrimSpace(l.ToMACAddress)),
+	}, "\x00")
+}
+
+// indexedSource records which source each entry came from as it is read. Entries are
+// passed on as copies so that the same entry read from two sources stays distinct.
+type indexedSource struct {
+	src LogSource
+	idx int
+	d   *dedupSource
+
+	started bool
+}
+
+type sourcedLog struct {
+	log *NetGearLog
+	idx int
+}
+
+func (s *indexedSource) Next() (*NetGearLog, error) {
+	log, err := s.src.Next()
+	if err != nil {
+		return nil, err
+	}
+	if !s.started {
+		s.d.first[s.idx], s.started = log.Time, true
+	}
+	cp := *log
+	s.d.src[&cp] = sourcedLog{log: log, idx: s.idx}
+	return &cp, nil
+}
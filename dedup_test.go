@@ -0,0 +1,133 @@
+package netgearlogs
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func readLogLines(t *testing.T) []string {
+	data, err := os.ReadFile("log.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func parseLines(lines []string) []*NetGearLog {
+	logs, _ := ParseNetGearLog(strings.NewReader(strings.Join(lines, "\n")))
+	return logs
+}
+
+// routerExport is a newest-first router export with a DHCP lease renewed twice within
+// one second.
+var routerExport = []string{
+	"[Time synchronized with NTP server] Friday, February 19, 2016 19:03:11",
+	"[DoS Attack: SYN/ACK Scan] from source: 167.114.206.157, port 9010, Friday, February 19, 2016 18:54:58",
+	"[DoS Attack: ACK Scan] from source: 31.13.69.203, port 443, Friday, February 19, 2016 18:41:39",
+	"[DHCP IP: 192.168.1.11] to MAC address 20:7d:74:70:da:1d, Friday, February 19, 2016 18:15:56",
+	"[DHCP IP: 192.168.1.11] to MAC address 20:7d:74:70:da:1d, Friday, February 19, 2016 18:15:56",
+	"[DoS Attack: SYN/ACK Scan] from source: 68.233.247.142, port 80, Friday, February 19, 2016 17:49:14",
+	"[Internet connected] IP address: 96.37.90.24, Friday, February 19, 2016 17:02:46",
+	"[DHCP IP: 192.168.1.5] to MAC address 7c:1e:52:e0:11:7a, Friday, February 19, 2016 16:55:32",
+	"[LAN access from remote] from 80.82.79.104:46177 to 192.168.1.9:8080, Friday, February 19, 2016 15:41:04",
+}
+
+func TestDedupLogsOverlappingExports(t *testing.T) {
+	whole := DedupLogs(parseLines(routerExport))
+	if len(whole) != len(routerExport) {
+		t.Fatalf("Expected a single export to keep all %d entries, got %d", len(routerExport), len(whole))
+	}
+	newer := parseLines(routerExport[:6])
+	older := parseLines(routerExport[2:])
+
+	split := DedupLogs(older, newer)
+	if len(split) != len(whole) {
+		t.Fatalf("Expected %d entries from overlapping exports, got %d", len(whole), len(split))
+	}
+	for i := range whole {
+		if dedupKey(whole[i]) != dedupKey(split[i]) {
+			t.Fatalf("Entry %d differs: %+v vs %+v", i, whole[i], split[i])
+		}
+	}
+	if !LogsSorted(split) {
+		t.Error("Expected deduplicated entries to be in ascending order")
+	}
+}
+
+func TestDedupLogsSeveralExports(t *testing.T) {
+	var exports [][]*NetGearLog
+	total := 0
+	for _, bounds := range [][2]int{{0, 4}, {1, 7}, {5, len(routerExport)}} {
+		export := parseLines(routerExport[bounds[0]:bounds[1]])
+		total += len(export)
+		exports = append(exports, export)
+	}
+	deduped := DedupLogs(exports...)
+	if len(deduped) != len(routerExport) {
+		t.Errorf("Expected %d of %d entries after removing overlap, got %d", len(routerExport), total, len(deduped))
+	}
+	for i, export := range exports {
+		if n := len(DedupLogs(deduped, export)); n != len(deduped) {
+			t.Errorf("Export %d is not contained in the deduplicated log: %d extra entries", i, n-len(deduped))
+		}
+	}
+}
+
+func TestDedupLogsSplitRepeats(t *testing.T) {
+	// In one second a lease was renewed, the admin logged in and the lease was renewed
+	// again. Each export holds one of the renewals, and both hold the login.
+	renewal := "[DHCP IP: 192.168.1.11] to MAC address 20:7d:74:70:da:1d, Friday, February 19, 2016 18:15:56"
+	login := "[admin login] from source 192.168.1.11, Friday, February 19, 2016 18:15:56"
+	older := parseLines([]string{login, renewal, routerExport[5]})
+	newer := parseLines([]string{routerExport[2], renewal, login})
+
+	for _, exports := range [][][]*NetGearLog{{older, newer}, {newer, older}} {
+		deduped := DedupLogs(exports...)
+		var events []string
+		for _, l := range deduped {
+			events = append(events, l.Kind())
+		}
+		want := []string{eventDoSAttackSynAckScan, eventDHCPIP, eventAdminLogin, eventDHCPIP, eventDoSAttackAckScan}
+		if strings.Join(events, ",") != strings.Join(want, ",") {
+			t.Errorf("Expected both renewals around the login, got %v", events)
+		}
+	}
+}
+
+func TestDedupLogsKeepsRepeatsWithinSecond(t *testing.T) {
+	ts := "Monday, February 22, 2016 18:31:22"
+	dhcp := func() *NetGearLog {
+		l := logAt(eventDHCPIP, "192.168.1.6", ts)
+		l.ToMACAddress = "b4:b6:76:bf:19:8b"
+		return l
+	}
+	a := []*NetGearLog{dhcp(), dhcp()}
+	b := []*NetGearLog{dhcp(), dhcp(), dhcp()}
+	upper := dhcp()
+	upper.ToMACAddress = "B4:B6:76:BF:19:8B"
+	c := []*NetGearLog{upper}
+
+	deduped := DedupLogs(a, b, c)
+	if len(deduped) != 3 {
+		t.Errorf("Expected 3 repeats to survive, got %d", len(deduped))
+	}
+	// Exports all from one second are taken to be newest first.
+	if deduped[0] != a[1] || deduped[1] != a[0] || deduped[2] != b[0] {
+		t.Error("Expected the earliest export's copies, then the latest repeat only the next one holds")
+	}
+}
+
+func TestKind(t *testing.T) {
+	tests := map[string]string{
+		eventAccessControl + " allowed":              eventAccessControl,
+		eventDynamicDNS + " registration successful": eventDynamicDNS,
+		eventDoSAttackSynAckScan:                     eventDoSAttackSynAckScan,
+	}
+	for event, kind := range tests {
+		l := &NetGearLog{EventType: event}
+		if l.Kind() != kind {
+			t.Errorf("%s: expected kind %s, got %s", event, kind, l.Kind())
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package netgearlogs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ECSVersion is the Elastic Common Schema version documents are written against.
+const ECSVersion = "8.11.0"
+
+// ECSDocument is a NetGearLog shaped for the Elastic Common Schema.
+type ECSDocument struct {
+	Timestamp   time.Time       `json:"@timestamp"`
+	ECS         ecsVersion      `json:"ecs"`
+	Event       ECSEvent        `json:"event"`
+	Source      *ECSEndpoint    `json:"source,omitempty"`
+	Destination *ECSEndpoint    `json:"destination,omitempty"`
+	Host        *ECSHost        `json:"host,omitempty"`
+	Observer    ECSObserver     `json:"observer"`
+	Log         *ECSLog         `json:"log,omitempty"`
+	Message     string          `json:"message,omitempty"`
+	Related     *ECSRelated     `json:"related,omitempty"`
+	NetGear     *ecsNetGearInfo `json:"netgear,omitempty"`
+}
+
+type ecsVersion struct {
+	Version string `json:"version"`
+}
+
+// ECSEvent holds the event.* fields.
+type ECSEvent struct {
+	Kind     string   `json:"kind"`
+	Category []string `json:"category"`
+	Type     []string `json:"type,omitempty"`
+	Action   string   `json:"action"`
+	Outcome  string   `json:"outcome,omitempty"`
+	Severity int      `json:"severity,omitempty"`
+	Original string   `json:"original,omitempty"`
+}
+
+// ECSEndpoint holds the source.* or destination.* fields.
+type ECSEndpoint struct {
+	IP     string `json:"ip,omitempty"`
+	Port   int    `json:"port,omitempty"`
+	Domain string `json:"domain,omitempty"`
+	MAC    string `json:"mac,omitempty"`
+}
+
+// ECSHost holds the host.* fields, used for the device in DHCP and WLAN entries.
+type ECSHost struct {
+	MAC  []string `json:"mac,omitempty"`
+	IP   []string `json:"ip,omitempty"`
+	Name string   `json:"name,omitempty"`
+}
+
+// ECSObserver holds the observer.* fields describing the router itself.
+type ECSObserver struct {
+	Vendor  string   `json:"vendor"`
+	Product string   `json:"product,omitempty"`
+	Type    string   `json:"type"`
+	IP      []string `json:"ip,omitempty"`
+}
+
+// ECSLog holds the log.* fields.
+type ECSLog struct {
+	File struct {
+		Path string `json:"path"`
+	} `json:"file"`
+}
+
+// ECSRelated holds the related.* fields used for pivoting between documents.
+type ECSRelated struct {
+	IP    []string `json:"ip,omitempty"`
+	Hosts []string `json:"hosts,omitempty"`
+}
+
+type ecsNetGearInfo struct {
+	EventType string `json:"event_type"`
+}
+
+// ecsMapping is the event.* classification of an event kind. Each type must be one
+// ECS allows for at least one of the categories.
+type ecsMapping struct {
+	kind     string
+	category []string
+	typ      []string
+}
+
+var ecsMappings = map[string]ecsMapping{
+	eventDoSAttackSynAckScan:    {"alert", []string{"network", "intrusion_detection"}, []string{"denied"}},
+	eventDoSAttackRstScan:       {"alert", []string{"network", "intrusion_detection"}, []string{"denied"}},
+	eventDoSAttackTCPUDPChargen: {"alert", []string{"network", "intrusion_detection"}, []string{"denied"}},
+	eventDoSAttackAckScan:       {"alert", []string{"network", "intrusion_detection"}, []string{"denied"}},
+	eventDoSAttackICMPScan:      {"alert", []string{"network", "intrusion_detection"}, []string{"denied"}},
+	eventDoSAttackARPAttack:     {"alert", []string{"network", "intrusion_detection"}, []string{"denied"}},
+	eventDoSAttackTCPUDPEcho:    {"alert", []string{"network", "intrusion_detection"}, []string{"denied"}},
+	eventWLANRejectIncorrectSec: {"event", []string{"network", "authentication"}, []string{"denied"}},
+	eventLANAccessFromRemote:    {"event", []string{"network"}, []string{"connection", "allowed"}},
+	eventAdminLogin:             {"event", []string{"authentication"}, []string{"start"}},
+	eventAccessControl:          {"event", []string{"network"}, []string{"allowed"}},
+	eventDHCPIP:                 {"event", []string{"network"}, []string{"connection"}},
+	eventInternetConnected:      {"event", []string{"network"}, []string{"connection", "start"}},
+	eventUPnPAddNatRule:         {"event", []string{"configuration", "network"}, []string{"creation"}},
+	eventUPnPDelNatRule:         {"event", []string{"configuration", "network"}, []string{"deletion"}},
+	eventTimeSyncNTP:            {"event", []string{"host"}, []string{"change"}},
+	eventDynamicDNS:             {"event", []string{"configuration", "network"}, []string{"change"}},
+	eventEmailSent:              {"event", []string{"email"}, []string{"info"}},
+	eventEmailFailed:            {"event", []string{"email"}, []string{"info"}},
+	eventSiteAllowed:            {"event", []string{"network", "web"}, []string{"allowed"}},
+	eventSiteBlocked:            {"event", []string{"network", "web"}, []string{"denied"}},
+}
+
+// ToECS converts the entry to an ECS document. Product names the router model for
+// observer.product; it may be empty.
+func (l *NetGearLog) ToECS(product string) *ECSDocument {
+	m, ok := ecsMappings[l.Kind()]
+	if !ok {
+		// Without a known kind there is no type to claim.
+		m = ecsMapping{"event", []string{"network"}, nil}
+	}
+	doc := &ECSDocument{
+		Timestamp: l.Time,
+		ECS:       ecsVersion{ECSVersion},
+		Event: ECSEvent{
+			Kind:     m.kind,
+			Category: m.category,
+			Type:     m.typ,
+			Action:   ecsAction(l.EventType),
+			Severity: siemEventFor(l).severity,
+			Original: l.Raw,
+		},
+		Observer: ECSObserver{Vendor: "NETGEAR", Product: product, Type: "router"},
+		Message:  l.Message,
+		NetGear:  &ecsNetGearInfo{EventType: l.EventType},
+	}
+	if l.File != "" {
+		doc.Log = &ECSLog{}
+		doc.Log.File.Path = l.File
+	}
+	related := &ECSRelated{}
+
+	switch l.Kind() {
+	case eventDHCPIP:
+		doc.Host = &ECSHost{MAC: []string{ecsMAC(l.ToMACAddress)}, IP: []string{l.FromSource}}
+		related.IP = append(related.IP, l.FromSource)
+	case eventWLANRejectIncorrectSec:
+		doc.Source = &ECSEndpoint{MAC: ecsMAC(l.ToMACAddress)}
+		doc.Event.Outcome = "failure"
+	case eventAccessControl:
+		doc.Host = &ECSHost{MAC: []string{ecsMAC(l.ToMACAddress)}, Name: l.Device}
+		if strings.HasSuffix(l.EventType, "blocked") {
+			doc.Event.Type = []string{"denied"}
+		}
+		related.Hosts = append(related.Hosts, l.Device)
+	case eventInternetConnected:
+		doc.Observer.IP = []string{l.FromSource}
+	case eventLANAccessFromRemote:
+		doc.Source = &ECSEndpoint{IP: l.SourceIP(), Port: l.SourcePort()}
+		doc.Destination = &ECSEndpoint{IP: l.DestHost(), Port: l.DestPort()}
+		related.IP = append(related.IP, l.SourceIP(), l.DestHost())
+	case eventDynamicDNS, eventEmailSent, eventEmailFailed:
+		if l.ToDest != "" {
+			doc.Destination = &ECSEndpoint{Domain: l.ToDest}
+		}
+		switch {
+		case l.Kind() == eventEmailFailed, strings.HasSuffix(l.EventType, "failure"):
+			doc.Event.Outcome = "failure"
+		default:
+			doc.Event.Outcome = "success"
+		}
+	case eventSiteAllowed, eventSiteBlocked:
+		doc.Source = &ECSEndpoint{IP: l.SourceIP()}
+		doc.Destination = &ECSEndpoint{Domain: l.ToDest}
+		related.IP = append(related.IP, l.SourceIP())
+		related.Hosts = append(related.Hosts, l.ToDest)
+	case eventAdminLogin:
+		doc.Source = &ECSEndpoint{IP: l.SourceIP()}
+		doc.Event.Outcome = "success"
+		related.IP = append(related.IP, l.SourceIP())
+	default:
+		if l.FromSource != "" {
+			doc.Source = &ECSEndpoint{IP: l.SourceIP(), Port: l.SourcePort()}
+			related.IP = append(related.IP, l.SourceIP())
+		}
+	}
+	if len(related.IP) > 0 || len(related.Hosts) > 0 {
+		doc.Related = related
+	}
+	return doc
+}
+
+// ecsAction turns an event type into the lower-case, hyphenated form used for
+// event.action, e.g. "dos-attack-syn-ack-scan".
+func ecsAction(eventType string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(eventType) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// ecsMAC formats a MAC address as ECS recommends: upper case, separated by hyphens.
+func ecsMAC(mac string) string {
+	return strings.ToUpper(strings.Replace(mac, ":", "-", -1))
+}
+
+// ECSBulkWriter writes entries as Elasticsearch bulk API NDJSON: an index action
+// line followed by the ECS document.
+type ECSBulkWriter struct {
+	// Index is the target index or data stream.
+	Index string
+	// Product is written as observer.product.
+	Product string
+
+	w io.Writer
+}
+
+// NewECSBulkWriter returns an ECSBulkWriter writing to w for the given index.
+func NewECSBulkWriter(w io.Writer, index string) *ECSBulkWriter {
+	return &ECSBulkWriter{w: w, Index: index, Product: "WNDR4300"}
+}
+
+// Write writes the action and document lines for a single entry. Data streams only
+// accept the create action, so that is what is used.
+func (b *ECSBulkWriter) Write(l *NetGearLog) error {
+	action := map[string]map[string]string{"create": {"_index": b.Index}}
+	a, err := json.Marshal(action)
+	if err != nil {
+		return err
+	}
+	doc, err := json.Marshal(l.ToECS(b.Product))
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	buf.Write(a)
+	buf.WriteByte('\n')
+	buf.Write(doc)
+	buf.WriteByte('\n')
+	_, err = b.w.Write(buf.Bytes())
+	return err
+}
+
+// ECSBulkClient sends entries to an Elasticsearch-compatible _bulk endpoint.
+type ECSBulkClient struct {
+	// URL is the base URL of the cluster, e.g. http://localhost:9200.
+	URL     string
+	Index   string
+	Product string
+	// Client is the HTTP client used; http.DefaultClient if nil.
+	Client *http.Client
+}
+
+// Send indexes logs in a single bulk request. It fails if the request fails or if
+// Elasticsearch reports an error for any item.
+func (c *ECSBulkClient) Send(logs []*NetGearLog) error {
+	var body bytes.Buffer
+	w := NewECSBulkWriter(&body, c.Index)
+	if c.Product != "" {
+		w.Product = c.Product
+	}
+	n := 0
+	for _, l := range logs {
+		if l == nil {
+			continue
+		}
+		if err := w.Write(l); err != nil {
+			return err
+		}
+		n++
+	}
+	if n == 0 {
+		return nil
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Post(strings.TrimRight(c.URL, "/")+"/_bulk", "application/x-ndjson", &body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("bulk request failed: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	var result struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int `json:"status"`
+			Error  *struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("decoding bulk response: %s", err)
+	}
+	if !result.Errors {
+		return nil
+	}
+	failed := 0
+	var first string
+	for _, item := range result.Items {
+		for _, r := range item {
+			if r.Error != nil {
+				if failed == 0 {
+					first = r.Error.Type + ": " + r.Error.Reason
+				}
+				failed++
+			}
+		}
+	}
+	return fmt.Errorf("%d of %d documents failed to index, first: %s", failed, n, first)
+}
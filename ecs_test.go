@@ -0,0 +1,179 @@
+package netgearlogs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestToECSDoSAttack(t *testing.T) {
+	l, err := ParseNetGearLogLine("[DoS Attack: SYN/ACK Scan] from source: 37.59.134.139, port 80, Monday, February 22, 2016 18:10:18")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(l.ToECS("WNDR4300"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"@timestamp":       "2016-02-22T18:10:18Z",
+		"event.kind":       "alert",
+		"event.action":     "dos-attack-syn-ack-scan",
+		"event.original":   l.Raw,
+		"source.ip":        "37.59.134.139",
+		"source.port":      80.0,
+		"observer.vendor":  "NETGEAR",
+		"observer.product": "WNDR4300",
+		"ecs.version":      ECSVersion,
+	}
+	for path, value := range expected {
+		if got := lookupPath(doc, path); got != value {
+			t.Errorf("%s: expected %v, got %v", path, value, got)
+		}
+	}
+	if cats := lookupPath(doc, "event.category").([]interface{}); len(cats) != 2 || cats[1] != "intrusion_detection" {
+		t.Errorf("Unexpected event.category: %v", cats)
+	}
+}
+
+func TestToECSDHCP(t *testing.T) {
+	l, err := ParseNetGearLogLine("[DHCP IP: 192.168.1.6] to MAC address b4:b6:76:bf:19:8b, Monday, February 22, 2016 18:31:22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := l.ToECS("")
+	if doc.Host == nil || doc.Host.MAC[0] != "B4-B6-76-BF-19-8B" || doc.Host.IP[0] != "192.168.1.6" {
+		t.Errorf("Unexpected host: %+v", doc.Host)
+	}
+	if doc.Source != nil {
+		t.Errorf("Expected no source, got %+v", doc.Source)
+	}
+}
+
+func TestECSMappingTypes(t *testing.T) {
+	// The event.type values ECS allows for each event.category.
+	allowed := map[string][]string{
+		"authentication":      {"start", "end", "info"},
+		"configuration":       {"access", "change", "creation", "deletion", "info"},
+		"email":               {"info"},
+		"host":                {"access", "change", "end", "info", "start"},
+		"intrusion_detection": {"allowed", "denied", "info"},
+		"network":             {"access", "allowed", "connection", "denied", "end", "info", "protocol", "start"},
+		"web":                 {"access", "error", "info"},
+	}
+	for kind, m := range ecsMappings {
+		for _, typ := range m.typ {
+			ok := false
+			for _, cat := range m.category {
+				for _, a := range allowed[cat] {
+					ok = ok || a == typ
+				}
+			}
+			if !ok {
+				t.Errorf("%s: type %s is not allowed for categories %v", kind, typ, m.category)
+			}
+		}
+	}
+
+	l, err := ParseNetGearLogLine("[email failed] internet connection is dropped, Wednesday, February 17, 2016 09:01:11")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc := l.ToECS(""); doc.Event.Type[0] != "info" || doc.Event.Outcome != "failure" {
+		t.Errorf("Expected a failed email to be an info event with a failure outcome, got %+v", doc.Event)
+	}
+	if doc := (&NetGearLog{EventType: "something new"}).ToECS(""); doc.Event.Type != nil {
+		t.Errorf("Expected no type for an unknown event, got %v", doc.Event.Type)
+	}
+}
+
+func lookupPath(doc map[string]interface{}, path string) interface{} {
+	var v interface{} = doc
+	for _, k := range strings.Split(path, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[k]
+	}
+	return v
+}
+
+func TestECSBulkClient(t *testing.T) {
+	var received []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_bulk" || r.Header.Get("Content-Type") != "application/x-ndjson" {
+			t.Errorf("Unexpected request %s %s", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		s := bufio.NewScanner(r.Body)
+		for s.Scan() {
+			received = append(received, s.Text())
+		}
+		io.WriteString(w, `{"took":1,"errors":false,"items":[]}`)
+	}))
+	defer srv.Close()
+
+	logs := parseLines([]string{
+		"[admin login] from source 192.168.1.6, Tuesday, February 23, 2016 19:06:07",
+		"[Internet connected] IP address: 96.37.90.24, Monday, February 22, 2016 17:02:59",
+	})
+	c := &ECSBulkClient{URL: srv.URL, Index: "logs-netgear-default"}
+	if err := c.Send(logs); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 4 {
+		t.Fatalf("Expected 4 NDJSON lines, got %d", len(received))
+	}
+	if received[0] != `{"create":{"_index":"logs-netgear-default"}}` {
+		t.Errorf("Unexpected action line %s", received[0])
+	}
+	var doc ECSDocument
+	if err := json.Unmarshal([]byte(received[3]), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Observer.IP) != 1 || doc.Observer.IP[0] != "96.37.90.24" {
+		t.Errorf("Expected observer.ip 96.37.90.24, got %v", doc.Observer.IP)
+	}
+}
+
+func TestECSBulkClientItemErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"errors":true,"items":[{"create":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"bad"}}}]}`)
+	}))
+	defer srv.Close()
+	c := &ECSBulkClient{URL: srv.URL, Index: "logs"}
+	err := c.Send(parseLines([]string{"[admin login] from source 192.168.1.6, Tuesday, February 23, 2016 19:06:07"}))
+	if err == nil || !strings.Contains(err.Error(), "mapper_parsing_exception") {
+		t.Errorf("Expected item error, got %v", err)
+	}
+}
+
+func TestECSBulkWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewECSBulkWriter(&buf, "netgear")
+	for _, l := range parseLines(readLogLines(t)) {
+		if l == nil {
+			continue
+		}
+		if err := w.Write(l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := bufio.NewScanner(&buf)
+	s.Buffer(nil, 1<<20)
+	for n := 0; s.Scan(); n++ {
+		var v map[string]interface{}
+		if err := json.Unmarshal(s.Bytes(), &v); err != nil {
+			t.Fatalf("Line %d is not JSON: %s", n+1, err)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package netgearlogs
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// BatchOptions control how exporters batch and retry entries. Zero values select the
+// defaults noted on each field.
+type BatchOptions struct {
+	// BatchSize is the number of entries sent at once. Defaults to 100.
+	BatchSize int
+	// BufferSize bounds the entries held while waiting to be sent. When it is
+	// exceeded, because the destination is down, the oldest entries are dropped.
+	// Defaults to 10000.
+	BufferSize int
+	// MaxRetries is the number of times a failed batch is retried before giving up
+	// until the next flush. Defaults to 5.
+	MaxRetries int
+	// Backoff is the delay before the first retry; it doubles after each attempt up
+	// to MaxBackoff. Default to 500ms and 30s. Once a batch has failed every retry,
+	// background sends wait MaxBackoff before trying again.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+func (o BatchOptions) batchSize() int {
+	if o.BatchSize > 0 {
+		return o.BatchSize
+	}
+	return 100
+}
+
+func (o BatchOptions) bufferSize() int {
+	if o.BufferSize > 0 {
+		return o.BufferSize
+	}
+	return 10000
+}
+
+func (o BatchOptions) maxRetries() int {
+	if o.MaxRetries > 0 {
+		return o.MaxRetries
+	}
+	return 5
+}
+
+func (o BatchOptions) backoff() (time.Duration, time.Duration) {
+	b, m := o.Backoff, o.MaxBackoff
+	if b <= 0 {
+		b = 500 * time.Millisecond
+	}
+	if m <= 0 {
+		m = 30 * time.Second
+	}
+	return b, m
+}
+
+// Errors returned by the batcher.
+var (
+	errNilEntry = errors.New("cannot export a nil log entry")
+	errClosed   = errors.New("exporter is closed")
+)
+
+// permanentError marks a send failure that retrying cannot fix, such as a request the
+// server rejects as malformed. The batch is dropped rather than retried.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+
+// partialError marks a batch that was delivered but had some of its entries rejected,
+// such as an OTLP partial success. The batch is not retried; only the rejected
+// entries are counted as dropped.
+type partialError struct {
+	rejected int
+	err      error
+}
+
+func (e partialError) Error() string { return e.err.Error() }
+
+// batcher buffers entries and sends them in batches with retries. It is shared by the
+// exporters, which supply the send function.
+//
+// Adding an entry never waits on the destination: once a full batch is buffered, a
+// background goroutine sends batches, retrying with backoff, until fewer than a
+// batch remain or a batch fails. Failed batches go back to the front of the buffer,
+// which keeps bounding memory while the destination is down, and background sends
+// then wait out MaxBackoff before trying again. Only one send is in
+// progress at a time; flush waits for it and then sends the rest.
+type batcher struct {
+	opts  BatchOptions
+	send  func([]*NetGearLog) error
+	sleep func(time.Duration)
+	now   func() time.Time
+
+	mu sync.Mutex
+	// idle is signalled when sending ends.
+	idle    *sync.Cond
+	sending bool
+	// retryAt is when background sends may start again after a batch failed.
+	retryAt time.Time
+	buf     []*NetGearLog
+	dropped int
+	// err is the last permanent or partial failure, returned at the end of the next
+	// flush.
+	err error
+	// closed refuses new entries; stopped refuses further sends once closing is done.
+	closed, stopped bool
+}
+
+func newBatcher(opts BatchOptions, send func([]*NetGearLog) error) *batcher {
+	b := &batcher{opts: opts, send: send, sleep: time.Sleep, now: time.Now}
+	b.idle = sync.NewCond(&b.mu)
+	return b
+}
+
+// add buffers l, dropping the oldest entry if the buffer is full, and starts sending
+// in the background once a batch is waiting and any backoff after a failed batch has
+// passed. It does not block on the destination.
+// A nil entry is refused, as it could not be encoded when the batch is sent.
+func (b *batcher) add(l *NetGearLog) error {
+	if l == nil {
+		return errNilEntry
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return errClosed
+	}
+	b.buf = append(b.buf, l)
+	b.trim()
+	if len(b.buf) >= b.opts.batchSize() && !b.sending && !b.now().Before(b.retryAt) {
+		b.sending = true
+		go b.drain()
+	}
+	return nil
+}
+
+// trim drops the oldest entries over the buffer size. b.mu must be held.
+func (b *batcher) trim() {
+	if over := len(b.buf) - b.opts.bufferSize(); over > 0 {
+		b.buf = b.buf[over:]
+		b.dropped += over
+	}
+}
+
+// take removes up to a batch of entries from the front of the buffer. b.mu must be
+// held.
+func (b *batcher) take() []*NetGearLog {
+	n := b.opts.batchSize()
+	if n > len(b.buf) {
+		n = len(b.buf)
+	}
+	batch := append([]*NetGearLog(nil), b.buf[:n]...)
+	b.buf = b.buf[n:]
+	return batch
+}
+
+// sendBatch sends batch with retries. A batch that fails for good is dropped; one
+// that may yet succeed goes back to the front of the buffer. A batch delivered with
+// some entries rejected counts as sent, with only those entries dropped. b.mu must
+// not be held.
+func (b *batcher) sendBatch(batch []*NetGearLog) error {
+	err := b.sendWithRetry(batch)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if pe, ok := err.(partialError); ok {
+		if pe.rejected > len(batch) {
+			pe.rejected = len(batch)
+		}
+		b.dropped += pe.rejected
+		b.err = err
+		b.retryAt = time.Time{}
+		return nil
+	}
+	if _, ok := err.(permanentError); ok {
+		b.dropped += len(batch)
+	} else if err != nil {
+		b.buf = append(batch, b.buf...)
+		b.trim()
+		_, max := b.opts.backoff()
+		b.retryAt = b.now().Add(max)
+	} else {
+		b.retryAt = time.Time{}
+	}
+	return err
+}
+
+// drain sends full batches in the background until fewer than a batch are buffered
+// or a send fails.
+func (b *batcher) drain() {
+	for {
+		b.mu.Lock()
+		if len(b.buf) < b.opts.batchSize() {
+			b.sending = false
+			b.idle.Broadcast()
+			b.mu.Unlock()
+			return
+		}
+		batch := b.take()
+		b.mu.Unlock()
+		err := b.sendBatch(batch)
+		if _, ok := err.(permanentError); ok {
+			b.mu.Lock()
+			b.err = err
+			b.mu.Unlock()
+		} else if err != nil {
+			b.mu.Lock()
+			b.sending = false
+			b.idle.Broadcast()
+			b.mu.Unlock()
+			return
+		}
+	}
+}
+
+// flush waits for any background send, then sends everything that is buffered.
+// Entries that could not be sent after retrying stay buffered for the next flush.
+// It returns the first error, or else the last permanent or partial failure since
+// the previous flush.
+func (b *batcher) flush() error {
+	b.mu.Lock()
+	for b.sending {
+		b.idle.Wait()
+	}
+	if b.stopped {
+		b.mu.Unlock()
+		return errClosed
+	}
+	b.sending = true
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		b.sending = false
+		b.idle.Broadcast()
+		b.mu.Unlock()
+	}()
+	for {
+		b.mu.Lock()
+		if len(b.buf) == 0 {
+			err := b.err
+			b.err = nil
+			b.mu.Unlock()
+			return err
+		}
+		batch := b.take()
+		b.mu.Unlock()
+		if err := b.sendBatch(batch); err != nil {
+			return err
+		}
+	}
+}
+
+// close refuses further entries, flushes those buffered and then, with no send in
+// progress or to come, calls release to free what send uses, such as a connection.
+func (b *batcher) close(release func() error) error {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	err := b.flush()
+	b.mu.Lock()
+	for b.sending {
+		b.idle.Wait()
+	}
+	b.stopped = true
+	b.mu.Unlock()
+	if rerr := release(); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// droppedCount returns the number of entries dropped so far.
+func (b *batcher) droppedCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dropped
+}
+
+func (b *batcher) sendWithRetry(batch []*NetGearLog) error {
+	delay, max := b.opts.backoff()
+	for attempt := 0; ; attempt++ {
+		err := b.send(batch)
+		if err == nil {
+			return nil
+		}
+		if _, ok := err.(partialError); ok {
+			return err
+		}
+		if _, ok := err.(permanentError); ok || attempt >= b.opts.maxRetries() {
+			return err
+		}
+		b.sleep(delay)
+		if delay *= 2; delay > max {
+			delay = max
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package netgearlogs
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBatcherRetriesWithBackoff(t *testing.T) {
+	var sizes []int
+	failures := 2
+	b := newBatcher(BatchOptions{BatchSize: 2, MaxRetries: 3, Backoff: time.Second, MaxBackoff: 3 * time.Second}, func(batch []*NetGearLog) error {
+		if failures > 0 {
+			failures--
+			return errors.New("unavailable")
+		}
+		sizes = append(sizes, len(batch))
+		return nil
+	})
+	var slept []time.Duration
+	b.sleep = func(d time.Duration) { slept = append(slept, d) }
+
+	for i := 0; i < 3; i++ {
+		if err := b.add(&NetGearLog{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(sizes) != 2 || sizes[0] != 2 || sizes[1] != 1 {
+		t.Errorf("Expected batches of 2 and 1, got %v", sizes)
+	}
+	if len(slept) != 2 || slept[0] != time.Second || slept[1] != 2*time.Second {
+		t.Errorf("Expected backoff of 1s then 2s, got %v", slept)
+	}
+}
+
+func TestBatcherBoundedBuffer(t *testing.T) {
+	b := newBatcher(BatchOptions{BatchSize: 10, BufferSize: 3, MaxRetries: 1}, func(batch []*NetGearLog) error {
+		return errors.New("down")
+	})
+	b.sleep = func(time.Duration) {}
+	for i := 0; i < 5; i++ {
+		b.add(&NetGearLog{Port: i})
+	}
+	if len(b.buf) != 3 || b.dropped != 2 {
+		t.Fatalf("Expected 3 buffered and 2 dropped, got %d and %d", len(b.buf), b.dropped)
+	}
+	if b.buf[0].Port != 2 {
+		t.Errorf("Expected the oldest entries to be dropped, first buffered is %d", b.buf[0].Port)
+	}
+	if err := b.flush(); err == nil {
+		t.Error("Expected flush to fail")
+	}
+	if len(b.buf) != 3 {
+		t.Errorf("Expected failed entries to stay buffered, got %d", len(b.buf))
+	}
+}
+
+func TestBatcherDropsOnPermanentError(t *testing.T) {
+	calls := 0
+	b := newBatcher(BatchOptions{}, func(batch []*NetGearLog) error {
+		calls++
+		return permanentError{errors.New("bad request")}
+	})
+	b.add(&NetGearLog{})
+	if err := b.flush(); err == nil {
+		t.Error("Expected an error")
+	}
+	if calls != 1 || len(b.buf) != 0 || b.dropped != 1 {
+		t.Errorf("Expected a single attempt and the entry dropped: calls=%d buffered=%d dropped=%d", calls, len(b.buf), b.dropped)
+	}
+}
+
+func TestBatcherRefusesNil(t *testing.T) {
+	b := newBatcher(BatchOptions{BatchSize: 1}, func(batch []*NetGearLog) error {
+		t.Error("Expected nothing to be sent")
+		return nil
+	})
+	if err := b.add(nil); err == nil {
+		t.Error("Expected a nil entry to be refused")
+	}
+	if err := b.flush(); err != nil || len(b.buf) != 0 {
+		t.Errorf("Expected nothing buffered, got %d entries and %v", len(b.buf), err)
+	}
+}
+
+func TestBatcherWaitsAfterFailure(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	b := newBatcher(BatchOptions{BatchSize: 1, MaxRetries: 1, MaxBackoff: time.Minute}, func(batch []*NetGearLog) error {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		return errors.New("down")
+	})
+	b.sleep = func(time.Duration) {}
+	now := time.Date(2016, 2, 22, 12, 0, 0, 0, time.UTC)
+	b.now = func() time.Time { return now }
+	// idle waits for the background send to finish and returns the attempts so far.
+	idle := func() int {
+		b.mu.Lock()
+		for b.sending {
+			b.idle.Wait()
+		}
+		b.mu.Unlock()
+		mu.Lock()
+		defer mu.Unlock()
+		return calls
+	}
+
+	b.add(&NetGearLog{})
+	if n := idle(); n != 2 {
+		t.Fatalf("Expected a send and a retry, got %d attempts", n)
+	}
+	for i := 0; i < 5; i++ {
+		b.add(&NetGearLog{})
+	}
+	if n := idle(); n != 2 {
+		t.Errorf("Expected no sends before the backoff passed, got %d attempts", n)
+	}
+	now = now.Add(time.Minute)
+	b.add(&NetGearLog{})
+	if n := idle(); n != 4 {
+		t.Errorf("Expected sending to resume after the backoff, got %d attempts", n)
+	}
+}
+
+func TestBatcherAddDoesNotBlock(t *testing.T) {
+	release := make(chan struct{})
+	b := newBatcher(BatchOptions{BatchSize: 2, BufferSize: 4, MaxRetries: 1}, func(batch []*NetGearLog) error {
+		<-release
+		return errors.New("down")
+	})
+	b.sleep = func(time.Duration) {}
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			b.add(&NetGearLog{Port: i})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected add to return while the destination hangs")
+	}
+	close(release)
+	if err := b.flush(); err == nil {
+		t.Error("Expected flush to fail")
+	}
+	if len(b.buf) != 4 || b.droppedCount() != 6 {
+		t.Errorf("Expected the buffer to stay bounded, got %d buffered and %d dropped", len(b.buf), b.droppedCount())
+	}
+	if b.buf[len(b.buf)-1].Port != 9 {
+		t.Errorf("Expected the newest entry kept, last buffered is %d", b.buf[len(b.buf)-1].Port)
+	}
+}
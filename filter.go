@@ -0,0 +1,561 @@
+package netgearlogs
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter reports whether an entry is selected.
+type Filter func(l *NetGearLog) bool
+
+// FilterError reports a problem in a filter expression and where it was found.
+type FilterError struct {
+	Expr string
+	// Offset is the byte offset in Expr at which the problem was found.
+	Offset int
+	Msg    string
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("filter: %s at column %d", e.Msg, e.Offset+1)
+}
+
+// CompileFilter compiles a filter expression such as
+//
+//	kind = "DoS Attack: SYN/ACK Scan" and src in 37.59.0.0/16 and port != 80 and time > 2016-02-20
+//
+// into a Filter. An expression is a set of conditions joined by "and" and "or",
+// negated with "not" and grouped with parentheses. Each condition compares a field
+// with a value:
+//
+//	kind, event, device, message, file   text
+//	src, dst                             addresses; dst also holds host names
+//	mac                                  MAC address
+//	port, dport                          numbers
+//	time                                 dates or times, e.g. 2016-02-20 or "2016-02-20 17:46:56"
+//
+// using = and != on any field, <, <=, > and >= on numbers and times, and ~ and !~ to
+// match a regular expression against the text, address or MAC fields. "in" and
+// "not in" test containment: an address in a CIDR block or a domain, a MAC address
+// under a prefix such as 10:a5:d0, or any field in a parenthesised list of values.
+//
+// Values may be double-quoted; inside quotes only \" and \\ are escapes, so regular
+// expressions can be written as they are. A time stands for the whole period it names:
+// time = 2016-02-20 selects that day, and time > 2016-02-20 selects what follows it.
+// Times are compared with entries' wall-clock times as the router wrote them.
+func CompileFilter(expr string) (Filter, error) {
+	toks, err := lexFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{expr: expr, toks: toks}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != filterEOF {
+		return nil, p.errorf(tok, "unexpected %q", tok.text)
+	}
+	return f, nil
+}
+
+// FilterLogs returns the entries selected by f.
+func FilterLogs(logs []*NetGearLog, f Filter) []*NetGearLog {
+	var selected []*NetGearLog
+	for _, l := range logs {
+		if l != nil && f(l) {
+			selected = append(selected, l)
+		}
+	}
+	return selected
+}
+
+// FilterSource is a LogSource returning only the entries of another source selected
+// by a Filter. Errors from the underlying source are passed through.
+type FilterSource struct {
+	src    LogSource
+	filter Filter
+}
+
+// NewFilterSource returns a FilterSource reading from src.
+func NewFilterSource(src LogSource, f Filter) *FilterSource {
+	return &FilterSource{src: src, filter: f}
+}
+
+// Next returns the next selected entry, or io.EOF at the end of the source.
+func (s *FilterSource) Next() (*NetGearLog, error) {
+	for {
+		l, err := s.src.Next()
+		if err != nil {
+			return l, err
+		}
+		if s.filter(l) {
+			return l, nil
+		}
+	}
+}
+
+type filterTokenKind int
+
+const (
+	filterEOF filterTokenKind = iota
+	filterWord
+	filterString
+	filterOp
+	filterLParen
+	filterRParen
+	filterComma
+)
+
+type filterToken struct {
+	kind filterTokenKind
+	text string
+	pos  int
+}
+
+// isKeyword reports whether tok is the given keyword, in any case.
+func (tok filterToken) isKeyword(kw string) bool {
+	return tok.kind == filterWord && strings.EqualFold(tok.text, kw)
+}
+
+// isFilterWordChar reports whether ch may appear in an unquoted value. This covers
+// addresses, CIDR blocks, MAC addresses, dates and times.
+func isFilterWordChar(ch byte) bool {
+	return isLetter(rune(ch)) || isDigit(rune(ch)) || strings.IndexByte("._:/-+*", ch) >= 0
+}
+
+func lexFilter(expr string) ([]filterToken, error) {
+	var toks []filterToken
+	for i := 0; i < len(expr); {
+		ch := expr[i]
+		switch {
+		case isWhitespace(rune(ch)) || ch == '\r':
+			i++
+		case ch == '(':
+			toks = append(toks, filterToken{filterLParen, "(", i})
+			i++
+		case ch == ')':
+			toks = append(toks, filterToken{filterRParen, ")", i})
+			i++
+		case ch == ',':
+			toks = append(toks, filterToken{filterComma, ",", i})
+			i++
+		case ch == '"':
+			var buf strings.Builder
+			j := i + 1
+			for ; j < len(expr) && expr[j] != '"'; j++ {
+				if expr[j] == '\\' && j+1 < len(expr) && (expr[j+1] == '"' || expr[j+1] == '\\') {
+					j++
+				}
+				buf.WriteByte(expr[j])
+			}
+			if j == len(expr) {
+				return nil, &FilterError{Expr: expr, Offset: i, Msg: "unterminated string"}
+			}
+			toks = append(toks, filterToken{filterString, buf.String(), i})
+			i = j + 1
+		case strings.IndexByte("=!<>~", ch) >= 0:
+			op := expr[i : i+1]
+			if i+1 < len(expr) && (expr[i+1] == '=' || (ch == '!' && expr[i+1] == '~')) {
+				op = expr[i : i+2]
+			}
+			if op == "!" {
+				return nil, &FilterError{Expr: expr, Offset: i, Msg: `unexpected "!"`}
+			}
+			toks = append(toks, filterToken{filterOp, op, i})
+			i += len(op)
+		case isFilterWordChar(ch):
+			j := i
+			for j < len(expr) && isFilterWordChar(expr[j]) {
+				j++
+			}
+			toks = append(toks, filterToken{filterWord, expr[i:j], i})
+			i = j
+		default:
+			return nil, &FilterError{Expr: expr, Offset: i, Msg: fmt.Sprintf("unexpected %q", ch)}
+		}
+	}
+	return append(toks, filterToken{filterEOF, "end of expression", len(expr)}), nil
+}
+
+type filterParser struct {
+	expr string
+	toks []filterToken
+	i    int
+}
+
+func (p *filterParser) peek() filterToken { return p.toks[p.i] }
+
+func (p *filterParser) next() filterToken {
+	tok := p.toks[p.i]
+	if tok.kind != filterEOF {
+		p.i++
+	}
+	return tok
+}
+
+func (p *filterParser) errorf(tok filterToken, format string, args ...interface{}) error {
+	return &FilterError{Expr: p.expr, Offset: tok.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *filterParser) parseOr() (Filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().isKeyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(e *NetGearLog) bool { return l(e) || right(e) }
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (Filter, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().isKeyword("and") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(e *NetGearLog) bool { return l(e) && right(e) }
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseNot() (Filter, error) {
+	if p.peek().isKeyword("not") {
+		p.next()
+		f, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return func(e *NetGearLog) bool { return !f(e) }, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *filterParser) parsePrimary() (Filter, error) {
+	tok := p.next()
+	if tok.kind == filterLParen {
+		f, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if end := p.next(); end.kind != filterRParen {
+			return nil, p.errorf(end, "expected \")\", found %q", end.text)
+		}
+		return f, nil
+	}
+	if tok.kind != filterWord {
+		return nil, p.errorf(tok, "expected a field name, found %q", tok.text)
+	}
+	field, ok := filterFields[strings.ToLower(tok.text)]
+	if !ok {
+		return nil, p.errorf(tok, "unknown field %q", tok.text)
+	}
+
+	opTok := p.next()
+	op := opTok.text
+	switch {
+	case opTok.isKeyword("in"):
+		op = "in"
+	case opTok.isKeyword("not") && p.peek().isKeyword("in"):
+		p.next()
+		op = "not in"
+	case opTok.kind != filterOp:
+		return nil, p.errorf(opTok, "expected an operator after %s, found %q", tok.text, opTok.text)
+	}
+
+	switch op {
+	case "in", "not in":
+		values, err := p.parseValues()
+		if err != nil {
+			return nil, err
+		}
+		var matchers []Filter
+		for _, v := range values {
+			m, err := p.matcher(field, v, true)
+			if err != nil {
+				return nil, err
+			}
+			matchers = append(matchers, m)
+		}
+		matchAny := func(e *NetGearLog) bool {
+			for _, m := range matchers {
+				if m(e) {
+					return true
+				}
+			}
+			return false
+		}
+		if op == "not in" {
+			return func(e *NetGearLog) bool { return !matchAny(e) }, nil
+		}
+		return matchAny, nil
+	}
+
+	v, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case "=", "==", "!=":
+		m, err := p.matcher(field, v, false)
+		if err != nil {
+			return nil, err
+		}
+		if op == "!=" {
+			return func(e *NetGearLog) bool { return !m(e) }, nil
+		}
+		return m, nil
+	case "~", "!~":
+		if field.text == nil {
+			return nil, p.errorf(opTok, "%s does not support %s", tok.text, op)
+		}
+		re, err := regexp.Compile(v.text)
+		if err != nil {
+			return nil, p.errorf(v, "invalid regular expression: %s", err)
+		}
+		get := field.text
+		if op == "!~" {
+			return func(e *NetGearLog) bool { return !re.MatchString(get(e)) }, nil
+		}
+		return func(e *NetGearLog) bool { return re.MatchString(get(e)) }, nil
+	case "<", "<=", ">", ">=":
+		switch {
+		case field.number != nil:
+			n, err := p.number(v)
+			if err != nil {
+				return nil, err
+			}
+			get := field.number
+			return func(e *NetGearLog) bool { return compareInts(op, get(e), n) }, nil
+		case field.time != nil:
+			start, end, err := p.timeSpan(v)
+			if err != nil {
+				return nil, err
+			}
+			get := field.time
+			return func(e *NetGearLog) bool { return compareTimeSpan(op, get(e), start, end) }, nil
+		}
+		return nil, p.errorf(opTok, "%s does not support %s", tok.text, op)
+	}
+	return nil, p.errorf(opTok, "unknown operator %q", op)
+}
+
+func (p *filterParser) parseValue() (filterToken, error) {
+	tok := p.next()
+	if tok.kind != filterWord && tok.kind != filterString {
+		return tok, p.errorf(tok, "expected a value, found %q", tok.text)
+	}
+	return tok, nil
+}
+
+// parseValues parses a single value or a parenthesised, comma-separated list.
+func (p *filterParser) parseValues() ([]filterToken, error) {
+	if p.peek().kind != filterLParen {
+		v, err := p.parseValue()
+		return []filterToken{v}, err
+	}
+	p.next()
+	var values []filterToken
+	for {
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+		switch tok := p.next(); tok.kind {
+		case filterComma:
+		case filterRParen:
+			return values, nil
+		default:
+			return nil, p.errorf(tok, "expected \",\" or \")\", found %q", tok.text)
+		}
+	}
+}
+
+// filterField describes how to read a field of an entry. Exactly one of number and
+// time is set for numeric and time fields; text is set for every field that can be
+// matched as text.
+type filterField struct {
+	text   func(*NetGearLog) string
+	number func(*NetGearLog) int
+	time   func(*NetGearLog) time.Time
+	addr   bool
+	mac    bool
+}
+
+var filterFields = map[string]filterField{
+	"kind":    {text: func(l *NetGearLog) string { return l.Kind() }},
+	"event":   {text: func(l *NetGearLog) string { return l.EventType }},
+	"device":  {text: func(l *NetGearLog) string { return l.Device }},
+	"message": {text: func(l *NetGearLog) string { return l.Message }},
+	"file":    {text: func(l *NetGearLog) string { return l.File }},
+	"src":     {text: func(l *NetGearLog) string { return l.SourceIP() }, addr: true},
+	"dst":     {text: func(l *NetGearLog) string { return l.DestHost() }, addr: true},
+	"mac":     {text: func(l *NetGearLog) string { return l.ToMACAddress }, mac: true},
+	"port":    {number: func(l *NetGearLog) int { return l.SourcePort() }},
+	"dport":   {number: func(l *NetGearLog) int { return l.DestPort() }},
+	"time":    {time: func(l *NetGearLog) time.Time { return l.Time }},
+}
+
+// matcher returns a Filter testing the field against v for equality or, if contains
+// is set, for containment.
+func (p *filterParser) matcher(field filterField, v filterToken, contains bool) (Filter, error) {
+	switch {
+	case field.number != nil:
+		n, err := p.number(v)
+		if err != nil {
+			return nil, err
+		}
+		get := field.number
+		return func(e *NetGearLog) bool { return get(e) == n }, nil
+	case field.time != nil:
+		start, end, err := p.timeSpan(v)
+		if err != nil {
+			return nil, err
+		}
+		get := field.time
+		return func(e *NetGearLog) bool { return compareTimeSpan("=", get(e), start, end) }, nil
+	case field.mac:
+		mac, ok := normalizeMAC(v.text)
+		if !ok || (!contains && strings.Count(mac, ":") != 5) {
+			return nil, p.errorf(v, "invalid MAC address %q", v.text)
+		}
+		get := field.text
+		if contains {
+			return func(e *NetGearLog) bool {
+				m, _ := normalizeMAC(get(e))
+				return strings.HasPrefix(m+":", mac+":")
+			}, nil
+		}
+		return func(e *NetGearLog) bool {
+			m, _ := normalizeMAC(get(e))
+			return m == mac
+		}, nil
+	case field.addr:
+		get := field.text
+		if contains && strings.Contains(v.text, "/") {
+			_, block, err := net.ParseCIDR(v.text)
+			if err != nil {
+				return nil, p.errorf(v, "invalid CIDR block %q", v.text)
+			}
+			return func(e *NetGearLog) bool {
+				ip := net.ParseIP(get(e))
+				return ip != nil && block.Contains(ip)
+			}, nil
+		}
+		if ip := net.ParseIP(v.text); ip != nil {
+			return func(e *NetGearLog) bool { return ip.Equal(net.ParseIP(get(e))) }, nil
+		}
+		host := strings.ToLower(strings.TrimSuffix(v.text, "."))
+		if contains {
+			return func(e *NetGearLog) bool {
+				h := strings.ToLower(get(e))
+				return h == host || strings.HasSuffix(h, "."+host)
+			}, nil
+		}
+		return func(e *NetGearLog) bool { return strings.EqualFold(get(e), host) }, nil
+	}
+	get := field.text
+	return func(e *NetGearLog) bool { return strings.EqualFold(get(e), v.text) }, nil
+}
+
+func (p *filterParser) number(v filterToken) (int, error) {
+	n, err := strconv.Atoi(v.text)
+	if err != nil {
+		return 0, p.errorf(v, "invalid number %q", v.text)
+	}
+	return n, nil
+}
+
+// filterTimeLayouts are the accepted time formats and the length of time each names.
+var filterTimeLayouts = []struct {
+	layout string
+	span   time.Duration
+}{
+	{"2006-01-02", 24 * time.Hour},
+	{"2006-01-02T15:04", time.Minute},
+	{"2006-01-02 15:04", time.Minute},
+	{"2006-01-02T15:04:05", time.Second},
+	{"2006-01-02 15:04:05", time.Second},
+	{time.RFC3339, time.Second},
+}
+
+// timeSpan parses v as a time, returning the period [start, end) it names.
+func (p *filterParser) timeSpan(v filterToken) (time.Time, time.Time, error) {
+	for _, f := range filterTimeLayouts {
+		if t, err := time.Parse(f.layout, v.text); err == nil {
+			return t, t.Add(f.span), nil
+		}
+	}
+	return time.Time{}, time.Time{}, p.errorf(v, "invalid time %q", v.text)
+}
+
+func compareInts(op string, a, b int) bool {
+	switch op {
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	}
+	return a == b
+}
+
+// compareTimeSpan compares t with the period [start, end).
+func compareTimeSpan(op string, t, start, end time.Time) bool {
+	switch op {
+	case "<":
+		return t.Before(start)
+	case "<=":
+		return t.Before(end)
+	case ">":
+		return !t.Before(end)
+	case ">=":
+		return !t.Before(start)
+	}
+	return !t.Before(start) && t.Before(end)
+}
+
+// normalizeMAC returns a MAC address or prefix in lower case with colon-separated,
+// two-digit octets, reporting whether s was one.
+func normalizeMAC(s string) (string, bool) {
+	if s == "" {
+		return "", false
+	}
+	octets := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool { return r == ':' || r == '-' })
+	if len(octets) == 0 || len(octets) > 6 {
+		return "", false
+	}
+	for i, o := range octets {
+		if len(o) > 2 {
+			return "", false
+		}
+		if _, err := strconv.ParseUint(o, 16, 8); err != nil {
+			return "", false
+		}
+		if len(o) == 1 {
+			octets[i] = "0" + o
+		}
+	}
+	return strings.Join(octets, ":"), true
+}
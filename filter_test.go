@@ -0,0 +1,129 @@
+package netgearlogs
+
+import (
+	"strings"
+	"testing"
+)
+
+var filterTestLines = []string{
+	"[DoS Attack: SYN/ACK Scan] from source: 37.59.134.139, port 80, Monday, February 22, 2016 18:10:18",
+	"[DoS Attack: SYN/ACK Scan] from source: 37.59.20.5, port 443, Sunday, February 21, 2016 08:00:00",
+	"[DoS Attack: TCP/UDP Chargen] from source: 185.94.111.1, port 52792, Monday, February 22, 2016 17:46:56",
+	"[LAN access from remote] from 80.82.79.104:46589 to 192.168.1.9:8080, Monday, February 22, 2016 13:11:37",
+	"[Site allowed: fonts.googleapis.com] from source 192.168.1.21, Wednesday, May 16,2018 08:59:58",
+	"[DHCP IP: 192.168.1.2] to MAC address 54:26:96:d1:a0:2f, Friday, February 19, 2016 22:47:27",
+	"[WLAN access rejected: incorrect security] from MAC address 10:a5:d0:cd:fc:19, Wednesday, February 17, 2016 16:52:35",
+}
+
+func TestCompileFilter(t *testing.T) {
+	logs := parseLines(filterTestLines)
+	if len(logs) != len(filterTestLines) {
+		t.Fatalf("Expected %d entries, got %d", len(filterTestLines), len(logs))
+	}
+	var tests = []struct {
+		expr string
+		want []int
+	}{
+		{`kind = "DoS Attack: SYN/ACK Scan" and src in 37.59.0.0/16 and port != 80 and time > 2016-02-20`, []int{1}},
+		{`kind = "DoS Attack: SYN/ACK Scan" and port != 80`, []int{1}},
+		{`src in 37.59.0.0/16 or src = 185.94.111.1`, []int{0, 1, 2}},
+		{`src not in (37.59.0.0/16, 192.168.0.0/16) and kind ~ "^DoS"`, []int{2}},
+		{`port >= 443 and port < 50000`, []int{1, 3}},
+		{`port in (80, 443)`, []int{0, 1}},
+		{`dport = 8080 and dst = 192.168.1.9`, []int{3}},
+		{`dst ~ "\.googleapis\.com$"`, []int{4}},
+		{`dst in googleapis.com`, []int{4}},
+		{`mac in 10:A5:D0 or mac = 54-26-96-d1-a0-2f`, []int{5, 6}},
+		{`mac !~ "." and not (kind = "Site allowed")`, []int{0, 1, 2, 3}},
+		{`time = 2016-02-22`, []int{0, 2, 3}},
+		{`time <= 2016-02-19`, []int{5, 6}},
+		{`time >= "2016-02-22 17:46:56" and time < 2016-02-23`, []int{0, 2}},
+		{`time > 2018-01-01T00:00:00Z`, []int{4}},
+		{`NOT event ~ "Scan" AND NOT kind in ("DHCP IP", "Site allowed")`, []int{2, 3, 6}},
+	}
+	for i, tt := range tests {
+		f, err := CompileFilter(tt.expr)
+		if err != nil {
+			t.Errorf("%d. %s: %s", i, tt.expr, err)
+			continue
+		}
+		var got []int
+		for j, l := range logs {
+			if f(l) {
+				got = append(got, j)
+			}
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%d. %s: expected %v, got %v", i, tt.expr, tt.want, got)
+			continue
+		}
+		for j := range got {
+			if got[j] != tt.want[j] {
+				t.Errorf("%d. %s: expected %v, got %v", i, tt.expr, tt.want, got)
+				break
+			}
+		}
+	}
+}
+
+func TestCompileFilterErrors(t *testing.T) {
+	var tests = []struct {
+		expr   string
+		offset int
+		msg    string
+	}{
+		{``, 0, "expected a field name"},
+		{`kind = "DoS`, 7, "unterminated string"},
+		{`colour = red`, 0, "unknown field"},
+		{`port = 80 and`, 13, "expected a field name"},
+		{`port = eighty`, 7, "invalid number"},
+		{`src in 37.59.0.0/33`, 7, "invalid CIDR"},
+		{`mac = 10:a5:d0`, 6, "invalid MAC"},
+		{`time > yesterday`, 7, "invalid time"},
+		{`kind < "DHCP IP"`, 5, "does not support"},
+		{`port ~ "8"`, 5, "does not support"},
+		{`dst ~ "("`, 6, "invalid regular expression"},
+		{`(port = 80`, 10, `expected ")"`},
+		{`port = 80 port = 81`, 10, "unexpected"},
+		{`port 80`, 5, "expected an operator"},
+		{`port ! 80`, 5, "unexpected"},
+		{`port = 80 & src = 1.2.3.4`, 10, "unexpected"},
+	}
+	for i, tt := range tests {
+		_, err := CompileFilter(tt.expr)
+		fe, ok := err.(*FilterError)
+		if !ok {
+			t.Errorf("%d. %q: expected a *FilterError, got %v", i, tt.expr, err)
+			continue
+		}
+		if fe.Offset != tt.offset || !strings.Contains(fe.Msg, tt.msg) {
+			t.Errorf("%d. %q: expected %q at %d, got %q at %d", i, tt.expr, tt.msg, tt.offset, fe.Msg, fe.Offset)
+		}
+	}
+}
+
+func TestFilterSource(t *testing.T) {
+	f, err := CompileFilter(`kind ~ "^DoS"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs := parseLines(filterTestLines)
+	if n := len(FilterLogs(logs, f)); n != 3 {
+		t.Errorf("Expected 3 entries from FilterLogs, got %d", n)
+	}
+	src := NewFilterSource(NewSliceSource(logs), f)
+	n := 0
+	for {
+		l, err := src.Next()
+		if err != nil {
+			break
+		}
+		if !strings.HasPrefix(l.Kind(), "DoS") {
+			t.Errorf("Unexpected entry %+v", l)
+		}
+		n++
+	}
+	if n != 3 {
+		t.Errorf("Expected 3 entries from FilterSource, got %d", n)
+	}
+}
@@ -0,0 +1,83 @@
+package netgearlogs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// noSpaceDate matches the end of a line written by firmware that leaves out the space
+// before the year.
+var noSpaceDate = regexp.MustCompile(`,\d{4} \d{2}:\d{2}:\d{2}$`)
+
+// dateLayout returns the date layout of the line the entry was parsed from, which is
+// recorded in Raw, or the older layout with a space if there is no Raw line.
+func (l *NetGearLog) dateLayout() string {
+	if noSpaceDate.MatchString(l.Raw) {
+		return netgearLogDateFmtNoSpace
+	}
+	return netgearLogDateFmt
+}
+
+// String formats the entry as a line in the router's own log format, the inverse of
+// ParseNetGearLogLine. It is built from the entry's fields rather than Raw, so an
+// entry that has been filtered or anonymized is written with its new values; only
+// the date layout is taken from Raw, so lines from either firmware come back as
+// they were.
+func (l *NetGearLog) String() string {
+	date := l.Time.Format(l.dateLayout())
+	switch l.Kind() {
+	case eventDoSAttackSynAckScan, eventDoSAttackRstScan, eventDoSAttackTCPUDPChargen,
+		eventDoSAttackAckScan, eventDoSAttackTCPUDPEcho:
+		return fmt.Sprintf("[%s] from source: %s, port %d, %s", l.EventType, l.FromSource, l.Port, date)
+	case eventDoSAttackICMPScan, eventDoSAttackARPAttack:
+		return fmt.Sprintf("[%s] from source: %s, %s", l.EventType, l.FromSource, date)
+	case eventWLANRejectIncorrectSec:
+		return fmt.Sprintf("[%s] from MAC address %s, %s", l.EventType, l.ToMACAddress, date)
+	case eventTimeSyncNTP:
+		return fmt.Sprintf("[%s] %s", l.EventType, date)
+	case eventDHCPIP:
+		return fmt.Sprintf("[%s: %s] to MAC address %s, %s", l.EventType, l.FromSource, l.ToMACAddress, date)
+	case eventInternetConnected:
+		return fmt.Sprintf("[%s] IP address: %s, %s", l.EventType, l.FromSource, date)
+	case eventUPnPAddNatRule, eventUPnPDelNatRule, eventAdminLogin:
+		return fmt.Sprintf("[%s] from source %s, %s", l.EventType, l.FromSource, date)
+	case eventLANAccessFromRemote:
+		return fmt.Sprintf("[%s] from %s to %s, %s", l.EventType, l.FromSource, l.ToDest, date)
+	case eventAccessControl:
+		status := strings.TrimSpace(strings.TrimPrefix(l.EventType, eventAccessControl))
+		return fmt.Sprintf("[%s] Device %s with MAC address %s is %s to access the network, %s",
+			eventAccessControl, l.Device, l.ToMACAddress, status, date)
+	case eventEmailSent:
+		return fmt.Sprintf("[%s: %s] %s", l.EventType, l.ToDest, date)
+	case eventEmailFailed:
+		return fmt.Sprintf("[%s] %s, %s", l.EventType, l.Message, date)
+	case eventDynamicDNS:
+		// The router misspells "registration" in these lines.
+		status := strings.TrimSpace(strings.TrimPrefix(l.EventType, eventDynamicDNS+" registration"))
+		return fmt.Sprintf("[%s] host name %s registeration %s, %s", eventDynamicDNS, l.ToDest, status, date)
+	case eventSiteAllowed, eventSiteBlocked:
+		return fmt.Sprintf("[%s: %s] from source %s, %s", l.EventType, l.ToDest, l.FromSource, date)
+	}
+	return fmt.Sprintf("[%s] %s", l.EventType, date)
+}
+
+// WriteNetGearLog writes logs to w in the router's own log format, one per line.
+// Nil entries are skipped.
+func WriteNetGearLog(w io.Writer, logs []*NetGearLog) error {
+	bw := bufio.NewWriter(w)
+	for _, l := range logs {
+		if l == nil {
+			continue
+		}
+		if _, err := bw.WriteString(l.String()); err != nil {
+			return err
+		}
+		if err := bw.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,76 @@
+package netgearlogs
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFormatRoundTripLogFile(t *testing.T) {
+	for i, line := range readLogLines(t) {
+		l, err := ParseNetGearLogLine(line)
+		if err != nil {
+			t.Fatalf("Line %d: %s", i+1, err)
+		}
+		formatted := l.String()
+		if formatted != line {
+			t.Errorf("Line %d: expected\n%s\ngot\n%s", i+1, line, formatted)
+		}
+		again, err := ParseNetGearLogLine(formatted)
+		if err != nil {
+			t.Fatalf("Line %d: formatted line did not parse: %s", i+1, err)
+		}
+		if again.String() != line {
+			t.Errorf("Line %d: formatted line did not format back to %s", i+1, line)
+		}
+		l.Raw, again.Raw = "", ""
+		if !reflect.DeepEqual(l, again) {
+			t.Errorf("Line %d: expected %+v, got %+v", i+1, l, again)
+		}
+	}
+}
+
+func TestFormatAnonymized(t *testing.T) {
+	l, err := ParseNetGearLogLine("[LAN access from remote] from 80.82.79.104:46589 to 192.168.1.9:8080, Monday, February 22, 2016 13:11:37")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.ToDest = "10.0.0.1:8080"
+	expected := "[LAN access from remote] from 80.82.79.104:46589 to 10.0.0.1:8080, Monday, February 22, 2016 13:11:37"
+	if l.String() != expected {
+		t.Errorf("Expected %s, got %s", expected, l.String())
+	}
+}
+
+func TestWriteNetGearLog(t *testing.T) {
+	lines := []string{
+		"[Time synchronized with NTP server] Monday, February 22, 2016 19:03:16",
+		"[email failed] internet connection is dropped, Wednesday, February 17, 2016 09:01:11",
+	}
+	logs := parseLines(lines)
+	var buf bytes.Buffer
+	if err := WriteNetGearLog(&buf, append(logs, nil)); err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Join(lines, "\n") + "\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestFormatKeepsDateLayout(t *testing.T) {
+	line := "[Internet connected] IP address: 73.52.2.16, Wednesday, May 16,2018 07:09:38"
+	l, err := ParseNetGearLogLine(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.String() != line {
+		t.Errorf("Expected %s, got %s", line, l.String())
+	}
+	l.Raw = ""
+	expected := "[Internet connected] IP address: 73.52.2.16, Wednesday, May 16, 2018 07:09:38"
+	if l.String() != expected {
+		t.Errorf("Expected an entry without Raw to use the spaced layout, got %s", l.String())
+	}
+}
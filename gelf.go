@@ -0,0 +1,201 @@
+package netgearlogs
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+)
+
+// GELF UDP chunking limits, from the Graylog documentation.
+const (
+	gelfDefaultChunkSize = 1420
+	gelfMaxChunks        = 128
+	gelfChunkHeaderSize  = 12
+)
+
+var gelfChunkMagic = []byte{0x1e, 0x0f}
+
+// GELFExporter sends entries to Graylog as GELF 1.1 messages, either over UDP, with
+// large messages split into chunks, or over TCP, with each message terminated by a
+// null byte. Entries are batched and retried according to BatchOptions.
+type GELFExporter struct {
+	// Network is "udp" or "tcp".
+	Network string
+	Addr    string
+	// Router names the router; it is sent as the GELF host field.
+	Router string
+	// ChunkSize is the largest UDP datagram sent. Defaults to 1420.
+	ChunkSize int
+	// Timeout bounds connecting and writing. Defaults to 5s.
+	Timeout time.Duration
+
+	conn net.Conn
+	b    *batcher
+}
+
+// NewGELFExporter returns an exporter sending to addr over network ("udp" or "tcp"),
+// with entries attributed to the named router.
+func NewGELFExporter(network, addr, router string, opts BatchOptions) *GELFExporter {
+	e := &GELFExporter{Network: network, Addr: addr, Router: router}
+	e.b = newBatcher(opts, e.send)
+	return e
+}
+
+// Export queues l. Full batches are sent in the background, so Export does not wait
+// on the destination.
+func (e *GELFExporter) Export(l *NetGearLog) error { return e.b.add(l) }
+
+// Flush sends every queued entry.
+func (e *GELFExporter) Flush() error { return e.b.flush() }
+
+// Dropped returns the number of entries discarded because the buffer overflowed or
+// the destination rejected them.
+func (e *GELFExporter) Dropped() int { return e.b.droppedCount() }
+
+// Close flushes queued entries, waiting for any background send, and closes the
+// connection. Entries exported after Close are refused.
+func (e *GELFExporter) Close() error {
+	return e.b.close(func() error {
+		if e.conn == nil {
+			return nil
+		}
+		err := e.conn.Close()
+		e.conn = nil
+		return err
+	})
+}
+
+func (e *GELFExporter) timeout() time.Duration {
+	if e.Timeout > 0 {
+		return e.Timeout
+	}
+	return 5 * time.Second
+}
+
+func (e *GELFExporter) send(batch []*NetGearLog) error {
+	if e.conn == nil {
+		conn, err := net.DialTimeout(e.Network, e.Addr, e.timeout())
+		if err != nil {
+			return err
+		}
+		e.conn = conn
+	}
+	var err error
+	switch e.Network {
+	case "tcp", "tcp4", "tcp6":
+		err = e.sendTCP(batch)
+	default:
+		err = e.sendUDP(batch)
+	}
+	if _, ok := err.(permanentError); !ok && err != nil {
+		// Reconnect on the next attempt.
+		e.conn.Close()
+		e.conn = nil
+	}
+	return err
+}
+
+func (e *GELFExporter) sendTCP(batch []*NetGearLog) error {
+	var buf bytes.Buffer
+	for _, l := range batch {
+		msg, err := GELFMessage(l, e.Router)
+		if err != nil {
+			return permanentError{err}
+		}
+		buf.Write(msg)
+		buf.WriteByte(0)
+	}
+	e.conn.SetWriteDeadline(time.Now().Add(e.timeout()))
+	_, err := e.conn.Write(buf.Bytes())
+	return err
+}
+
+func (e *GELFExporter) sendUDP(batch []*NetGearLog) error {
+	size := e.ChunkSize
+	if size <= gelfChunkHeaderSize {
+		size = gelfDefaultChunkSize
+	}
+	for _, l := range batch {
+		msg, err := GELFMessage(l, e.Router)
+		if err != nil {
+			return permanentError{err}
+		}
+		chunks, err := gelfChunks(msg, size)
+		if err != nil {
+			return permanentError{err}
+		}
+		for _, c := range chunks {
+			e.conn.SetWriteDeadline(time.Now().Add(e.timeout()))
+			if _, err := e.conn.Write(c); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// gelfChunks splits msg into datagrams of at most size bytes. Messages that fit are
+// returned whole; larger ones are split into GELF chunks sharing a random message ID.
+func gelfChunks(msg []byte, size int) ([][]byte, error) {
+	if len(msg) <= size {
+		return [][]byte{msg}, nil
+	}
+	payload := size - gelfChunkHeaderSize
+	count := (len(msg) + payload - 1) / payload
+	if count > gelfMaxChunks {
+		return nil, fmt.Errorf("GELF message of %d bytes needs %d chunks, more than %d", len(msg), count, gelfMaxChunks)
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	chunks := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * payload
+		if end > len(msg) {
+			end = len(msg)
+		}
+		c := make([]byte, 0, gelfChunkHeaderSize+end-i*payload)
+		c = append(c, gelfChunkMagic...)
+		c = append(c, id...)
+		c = append(c, byte(i), byte(count))
+		c = append(c, msg[i*payload:end]...)
+		chunks = append(chunks, c)
+	}
+	return chunks, nil
+}
+
+// GELFMessage encodes the entry as a GELF 1.1 JSON message from the named router.
+// Network fields and the event kind are sent as additional fields.
+func GELFMessage(l *NetGearLog, router string) ([]byte, error) {
+	ev := siemEventFor(l)
+	msg := map[string]interface{}{
+		"version":       "1.1",
+		"host":          router,
+		"short_message": l.String(),
+		"timestamp":     float64(l.Time.UnixMilli()) / 1000,
+		"level":         syslogLevel(ev.severity),
+		"_event_kind":   l.Kind(),
+		"_event_type":   l.EventType,
+	}
+	if l.Raw != "" && l.Raw != msg["short_message"] {
+		msg["full_message"] = l.Raw
+	}
+	add := func(key, value string) {
+		if value != "" {
+			msg[key] = value
+		}
+	}
+	add("_source_ip", l.SourceIP())
+	if p := l.SourcePort(); p != 0 {
+		msg["_source_port"] = p
+	}
+	add("_dest", l.ToDest)
+	add("_mac", l.ToMACAddress)
+	add("_device", l.Device)
+	add("_file", l.File)
+	return json.Marshal(msg)
+}
@@ -0,0 +1,166 @@
+package netgearlogs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGELFExporterUDPChunked(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	e := NewGELFExporter("udp", pc.LocalAddr().String(), "wndr4300", BatchOptions{})
+	e.ChunkSize = 100
+	l, err := ParseNetGearLogLine("[LAN access from remote] from 80.82.79.104:46589 to 192.168.1.9:8080, Monday, February 22, 2016 13:11:37")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Export(l); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	chunks := make(map[byte][]byte)
+	var count byte
+	buf := make([]byte, 2048)
+	for count == 0 || len(chunks) < int(count) {
+		pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n > 100 {
+			t.Fatalf("Datagram of %d bytes exceeds the chunk size", n)
+		}
+		if !bytes.HasPrefix(buf, gelfChunkMagic) {
+			t.Fatalf("Expected a chunked message, got %q", buf[:n])
+		}
+		count = buf[11]
+		chunks[buf[10]] = append([]byte(nil), buf[12:n]...)
+	}
+	var msg []byte
+	for i := byte(0); i < count; i++ {
+		msg = append(msg, chunks[i]...)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(msg, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["host"] != "wndr4300" || m["_event_kind"] != eventLANAccessFromRemote || m["_source_ip"] != "80.82.79.104" {
+		t.Errorf("Unexpected message %v", m)
+	}
+	if m["timestamp"] != 1456146697.0 {
+		t.Errorf("Unexpected timestamp %v", m["timestamp"])
+	}
+}
+
+func TestGELFExporterCloseWhileSending(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	e := NewGELFExporter("udp", pc.LocalAddr().String(), "wndr4300", BatchOptions{BatchSize: 1})
+	l := &NetGearLog{EventType: eventAdminLogin, FromSource: "192.168.1.6"}
+	// Each export starts a background send that Close has to wait for.
+	exported := make(chan struct{})
+	go func() {
+		defer close(exported)
+		for i := 0; i < 500; i++ {
+			if e.Export(l) != nil {
+				return
+			}
+		}
+	}()
+	e.Export(l)
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	<-exported
+	if err := e.Export(l); err == nil {
+		t.Error("Expected an export after Close to be refused")
+	}
+	if err := e.Flush(); err == nil {
+		t.Error("Expected a flush after Close to fail")
+	}
+}
+
+func TestGELFExporterTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		defer conn.Close()
+		s := bufio.NewScanner(conn)
+		s.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+			if i := bytes.IndexByte(data, 0); i >= 0 {
+				return i + 1, data[:i], nil
+			}
+			return 0, nil, nil
+		})
+		var msgs []string
+		for s.Scan() {
+			msgs = append(msgs, s.Text())
+		}
+		received <- msgs
+	}()
+
+	e := NewGELFExporter("tcp", ln.Addr().String(), "wndr4300", BatchOptions{BatchSize: 2})
+	for _, l := range parseLines(readLogLines(t)[:5]) {
+		if err := e.Export(l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	msgs := <-received
+	if len(msgs) != 5 {
+		t.Fatalf("Expected 5 messages, got %d", len(msgs))
+	}
+	if !strings.Contains(msgs[0], `"short_message":"[email failed]`) {
+		t.Errorf("Unexpected first message %s", msgs[0])
+	}
+}
+
+func TestGELFExporterRetriesConnection(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	e := NewGELFExporter("tcp", addr, "wndr4300", BatchOptions{MaxRetries: 2})
+	var slept int
+	e.b.sleep = func(time.Duration) { slept++ }
+	e.Export(&NetGearLog{EventType: eventAdminLogin})
+	if err := e.Flush(); err == nil {
+		t.Fatal("Expected an error with nothing listening")
+	}
+	if slept != 2 {
+		t.Errorf("Expected 2 retries, got %d", slept)
+	}
+	if len(e.b.buf) != 1 {
+		t.Errorf("Expected the entry to stay buffered")
+	}
+}
@@ -0,0 +1,338 @@
+package netgearlogs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IPInfo is what the local databases know about an address.
+type IPInfo struct {
+	// Country is the ISO 3166-1 alpha-2 code of the country, e.g. "FR".
+	Country     string `json:"country,omitempty"`
+	CountryName string `json:"country_name,omitempty"`
+	ASN         int    `json:"asn,omitempty"`
+	ASOrg       string `json:"as_org,omitempty"`
+}
+
+// ipRange is an inclusive range of addresses, in their 16-byte form.
+type ipRange struct {
+	start, end [16]byte
+}
+
+type countryRange struct {
+	ipRange
+	code, name string
+}
+
+type asnRange struct {
+	ipRange
+	asn     int
+	org     string
+	country string
+}
+
+// GeoDatabase looks up the country and autonomous system of addresses from locally
+// supplied databases: the MaxMind GeoLite2 Country and ASN CSV files and the
+// IP-to-ASN TSV file from iptoasn.com. Each is loaded into a table of address ranges
+// sorted by start, so a lookup is a binary search. Nothing is fetched over the network.
+type GeoDatabase struct {
+	countries []countryRange
+	// geoASNs and ip2asn are kept apart because their ranges overlap each other.
+	geoASNs []asnRange
+	ip2asn  []asnRange
+}
+
+// GeoFiles names the database files to load with OpenGeoDatabase. Any may be empty.
+type GeoFiles struct {
+	// CountryBlocks are GeoLite2-Country-Blocks-IPv4.csv and -IPv6.csv, and
+	// CountryLocations is the GeoLite2-Country-Locations file naming their countries.
+	CountryBlocks    []string
+	CountryLocations string
+	// ASNBlocks are GeoLite2-ASN-Blocks-IPv4.csv and -IPv6.csv.
+	ASNBlocks []string
+	// IP2ASN is an ip2asn-v4.tsv, ip2asn-v6.tsv or ip2asn-combined.tsv file.
+	IP2ASN string
+}
+
+// OpenGeoDatabase loads the given database files.
+func OpenGeoDatabase(files GeoFiles) (*GeoDatabase, error) {
+	db := &GeoDatabase{}
+	if len(files.CountryBlocks) > 0 {
+		locations, err := os.ReadFile(files.CountryLocations)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range files.CountryBlocks {
+			if err := loadFile(path, func(r io.Reader) error {
+				return db.LoadGeoLite2Country(r, bytes.NewReader(locations))
+			}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, path := range files.ASNBlocks {
+		if err := loadFile(path, db.LoadGeoLite2ASN); err != nil {
+			return nil, err
+		}
+	}
+	if files.IP2ASN != "" {
+		if err := loadFile(files.IP2ASN, db.LoadIP2ASN); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+func loadFile(path string, load func(io.Reader) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := load(f); err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	return nil
+}
+
+// readCSVTable reads a CSV file with a header line, calling fn with each record and a
+// function returning the named column of it.
+func readCSVTable(r io.Reader, fn func(line int, col func(string) string) error) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	header, err := cr.Read()
+	if err != nil {
+		return err
+	}
+	index := make(map[string]int)
+	for i, h := range header {
+		index[strings.TrimPrefix(strings.TrimSpace(h), "\ufeff")] = i
+	}
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		col := func(name string) string {
+			if i, ok := index[name]; ok && i < len(rec) {
+				return rec[i]
+			}
+			return ""
+		}
+		if err := fn(line, col); err != nil {
+			return err
+		}
+	}
+}
+
+// LoadGeoLite2Country adds the networks in a GeoLite2 Country blocks CSV file, naming
+// their countries from the matching locations CSV file.
+func (db *GeoDatabase) LoadGeoLite2Country(blocks, locations io.Reader) error {
+	type country struct{ code, name string }
+	countries := make(map[string]country)
+	err := readCSVTable(locations, func(line int, col func(string) string) error {
+		countries[col("geoname_id")] = country{col("country_iso_code"), col("country_name")}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("locations: %s", err)
+	}
+	err = readCSVTable(blocks, func(line int, col func(string) string) error {
+		r, err := parseNetwork(col("network"))
+		if err != nil {
+			return fmt.Errorf("line %d: %s", line, err)
+		}
+		id := col("geoname_id")
+		if id == "" {
+			id = col("registered_country_geoname_id")
+		}
+		c, ok := countries[id]
+		if !ok {
+			return nil
+		}
+		db.countries = append(db.countries, countryRange{r, c.code, c.name})
+		return nil
+	})
+	sort.Slice(db.countries, func(i, j int) bool { return db.countries[i].less(db.countries[j].ipRange) })
+	return err
+}
+
+// LoadGeoLite2ASN adds the networks in a GeoLite2 ASN blocks CSV file.
+func (db *GeoDatabase) LoadGeoLite2ASN(blocks io.Reader) error {
+	err := readCSVTable(blocks, func(line int, col func(string) string) error {
+		r, err := parseNetwork(col("network"))
+		if err != nil {
+			return fmt.Errorf("line %d: %s", line, err)
+		}
+		asn, err := strconv.Atoi(col("autonomous_system_number"))
+		if err != nil {
+			return fmt.Errorf("line %d: bad AS number %q", line, col("autonomous_system_number"))
+		}
+		db.geoASNs = append(db.geoASNs, asnRange{ipRange: r, asn: asn, org: col("autonomous_system_organization")})
+		return nil
+	})
+	sortASNs(db.geoASNs)
+	return err
+}
+
+// LoadIP2ASN adds the ranges in an iptoasn.com TSV file, whose lines hold the first
+// and last address of a range, its AS number, country code and AS description.
+// Ranges marked as not routed are skipped.
+func (db *GeoDatabase) LoadIP2ASN(r io.Reader) error {
+	s := bufio.NewScanner(r)
+	line := 0
+	for s.Scan() {
+		line++
+		text := s.Text()
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		fields := strings.SplitN(text, "\t", 5)
+		if len(fields) < 4 {
+			sortASNs(db.ip2asn)
+			return fmt.Errorf("line %d: expected at least 4 fields, got %d", line, len(fields))
+		}
+		start, end := net.ParseIP(fields[0]), net.ParseIP(fields[1])
+		asn, err := strconv.Atoi(fields[2])
+		if start == nil || end == nil || err != nil {
+			sortASNs(db.ip2asn)
+			return fmt.Errorf("line %d: bad range %q", line, text)
+		}
+		if asn == 0 {
+			continue
+		}
+		var rng ipRange
+		copy(rng.start[:], start.To16())
+		copy(rng.end[:], end.To16())
+		ar := asnRange{ipRange: rng, asn: asn}
+		if c := fields[3]; c != "None" && c != "" {
+			ar.country = c
+		}
+		if len(fields) == 5 {
+			ar.org = fields[4]
+		}
+		db.ip2asn = append(db.ip2asn, ar)
+	}
+	sortASNs(db.ip2asn)
+	return s.Err()
+}
+
+func sortASNs(asns []asnRange) {
+	sort.Slice(asns, func(i, j int) bool { return asns[i].less(asns[j].ipRange) })
+}
+
+func (r ipRange) less(o ipRange) bool {
+	return bytes.Compare(r.start[:], o.start[:]) < 0
+}
+
+func (r ipRange) contains(ip [16]byte) bool {
+	return bytes.Compare(r.start[:], ip[:]) <= 0 && bytes.Compare(ip[:], r.end[:]) <= 0
+}
+
+// parseNetwork returns the range of addresses in a CIDR block.
+func parseNetwork(cidr string) (ipRange, error) {
+	_, n, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return ipRange{}, err
+	}
+	var r ipRange
+	start := n.IP.To16()
+	mask := n.Mask
+	if len(mask) == net.IPv4len {
+		// Widen the mask to cover the IPv4-mapped prefix of the 16-byte form.
+		mask = append(net.IPMask{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, mask...)
+	}
+	copy(r.start[:], start)
+	for i := range r.end {
+		r.end[i] = start[i] | ^mask[i]
+	}
+	return r, nil
+}
+
+// searchRanges returns the index of the last of n ranges, sorted by start, that
+// starts at or before ip, or -1 if there is none.
+func searchRanges(n int, start func(i int) [16]byte, ip [16]byte) int {
+	return sort.Search(n, func(i int) bool {
+		s := start(i)
+		return bytes.Compare(s[:], ip[:]) > 0
+	}) - 1
+}
+
+func lookupASN(asns []asnRange, ip [16]byte) (asnRange, bool) {
+	i := searchRanges(len(asns), func(i int) [16]byte { return asns[i].start }, ip)
+	if i < 0 || !asns[i].contains(ip) {
+		return asnRange{}, false
+	}
+	return asns[i], true
+}
+
+// Lookup returns what is known about ip, reporting false if nothing is. Where
+// GeoLite2 and IP-to-ASN data both cover an address, GeoLite2 is preferred.
+func (db *GeoDatabase) Lookup(ip string) (IPInfo, bool) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return IPInfo{}, false
+	}
+	var key [16]byte
+	copy(key[:], parsed.To16())
+	var info IPInfo
+	found := false
+	if i := searchRanges(len(db.countries), func(i int) [16]byte { return db.countries[i].start }, key); i >= 0 && db.countries[i].contains(key) {
+		info.Country, info.CountryName = db.countries[i].code, db.countries[i].name
+		found = true
+	}
+	a, ok := lookupASN(db.geoASNs, key)
+	if !ok {
+		a, ok = lookupASN(db.ip2asn, key)
+	}
+	if ok {
+		info.ASN, info.ASOrg = a.asn, a.org
+		if info.Country == "" {
+			info.Country = a.country
+		}
+		found = true
+	}
+	return info, found
+}
+
+// SourceInfo looks up the source address of a DoS Attack or LAN access from remote
+// entry, the entries whose source is a remote host. It reports false for other
+// entries and for addresses the databases do not cover.
+func (db *GeoDatabase) SourceInfo(l *NetGearLog) (IPInfo, bool) {
+	kind := l.Kind()
+	if !strings.HasPrefix(kind, eventDoSAttack) && kind != eventLANAccessFromRemote {
+		return IPInfo{}, false
+	}
+	return db.Lookup(l.SourceIP())
+}
+
+// EnrichedLog is an entry together with what is known about its source address.
+type EnrichedLog struct {
+	Log    *NetGearLog
+	Source IPInfo
+}
+
+// Enrich pairs each entry with the result of SourceInfo.
+func (db *GeoDatabase) Enrich(logs []*NetGearLog) []EnrichedLog {
+	enriched := make([]EnrichedLog, 0, len(logs))
+	for _, l := range logs {
+		if l == nil {
+			continue
+		}
+		info, _ := db.SourceInfo(l)
+		enriched = append(enriched, EnrichedLog{Log: l, Source: info})
+	}
+	return enriched
+}
@@ -0,0 +1,118 @@
+package netgearlogs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	testCountryBlocks = `network,geoname_id,registered_country_geoname_id,represented_country_geoname_id,is_anonymous_proxy,is_satellite_provider
+37.59.0.0/16,3017382,3017382,,0,0
+80.82.64.0/20,2750405,2750405,,0,0
+185.94.111.0/24,,2017370,,0,0
+2001:41d0::/32,3017382,3017382,,0,0
+`
+	testCountryLocations = `geoname_id,locale_code,continent_code,continent_name,country_iso_code,country_name,is_in_european_union
+2017370,en,EU,Europe,RU,Russia,0
+2750405,en,EU,Europe,NL,Netherlands,1
+3017382,en,EU,Europe,FR,France,1
+`
+	testASNBlocks = `network,autonomous_system_number,autonomous_system_organization
+37.59.0.0/16,16276,OVH SAS
+2001:41d0::/32,16276,OVH SAS
+`
+	testIP2ASN = "37.59.0.0\t37.59.255.255\t16276\tFR\tOVH\n" +
+		"80.82.64.0\t80.82.79.255\t202425\tSC\tINT-NETWORK\n" +
+		"185.94.111.0\t185.94.111.255\t0\tNone\tNot routed\n" +
+		"195.179.0.0\t195.179.255.255\t12345\tDE\tEXAMPLE-AS\n"
+)
+
+func testGeoDatabase(t *testing.T) *GeoDatabase {
+	db := &GeoDatabase{}
+	if err := db.LoadGeoLite2Country(strings.NewReader(testCountryBlocks), strings.NewReader(testCountryLocations)); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.LoadGeoLite2ASN(strings.NewReader(testASNBlocks)); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.LoadIP2ASN(strings.NewReader(testIP2ASN)); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestGeoDatabaseLookup(t *testing.T) {
+	db := testGeoDatabase(t)
+	var tests = []struct {
+		ip   string
+		want IPInfo
+		ok   bool
+	}{
+		{"37.59.134.139", IPInfo{Country: "FR", CountryName: "France", ASN: 16276, ASOrg: "OVH SAS"}, true},
+		{"80.82.79.104", IPInfo{Country: "NL", CountryName: "Netherlands", ASN: 202425, ASOrg: "INT-NETWORK"}, true},
+		{"80.82.80.1", IPInfo{}, false},
+		{"185.94.111.1", IPInfo{Country: "RU", CountryName: "Russia"}, true},
+		{"195.179.119.177", IPInfo{Country: "DE", ASN: 12345, ASOrg: "EXAMPLE-AS"}, true},
+		{"2001:41d0:1:2::3", IPInfo{Country: "FR", CountryName: "France", ASN: 16276, ASOrg: "OVH SAS"}, true},
+		{"37.58.255.255", IPInfo{}, false},
+		{"not an address", IPInfo{}, false},
+	}
+	for i, tt := range tests {
+		got, ok := db.Lookup(tt.ip)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%d. %s: expected %+v, %t, got %+v, %t", i, tt.ip, tt.want, tt.ok, got, ok)
+		}
+	}
+}
+
+func TestGeoDatabaseSourceInfo(t *testing.T) {
+	db := testGeoDatabase(t)
+	logs := parseLines([]string{
+		"[DoS Attack: SYN/ACK Scan] from source: 37.59.134.139, port 80, Monday, February 22, 2016 18:10:18",
+		"[LAN access from remote] from 80.82.79.104:46589 to 192.168.1.9:8080, Monday, February 22, 2016 13:11:37",
+		"[admin login] from source 37.59.1.1, Monday, February 22, 2016 13:11:37",
+	})
+	enriched := db.Enrich(logs)
+	if len(enriched) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(enriched))
+	}
+	if enriched[0].Source.Country != "FR" || enriched[0].Log != logs[0] {
+		t.Errorf("Unexpected DoS enrichment %+v", enriched[0])
+	}
+	if enriched[1].Source.ASN != 202425 {
+		t.Errorf("Unexpected LAN access enrichment %+v", enriched[1])
+	}
+	if _, ok := db.SourceInfo(logs[2]); ok {
+		t.Error("Expected only DoS and LAN access entries to be enriched")
+	}
+}
+
+func TestOpenGeoDatabase(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	db, err := OpenGeoDatabase(GeoFiles{
+		CountryBlocks:    []string{write("GeoLite2-Country-Blocks-IPv4.csv", testCountryBlocks)},
+		CountryLocations: write("GeoLite2-Country-Locations-en.csv", testCountryLocations),
+		ASNBlocks:        []string{write("GeoLite2-ASN-Blocks-IPv4.csv", testASNBlocks)},
+		IP2ASN:           write("ip2asn-v4.tsv", testIP2ASN),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := db.Lookup("37.59.134.139"); info.ASN != 16276 || info.Country != "FR" {
+		t.Errorf("Unexpected lookup %+v", info)
+	}
+
+	_, err = OpenGeoDatabase(GeoFiles{IP2ASN: write("bad.tsv", "1.2.3.4\tnope\t1\tUS\n")})
+	if err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("Expected an error naming the bad line, got %v", err)
+	}
+}
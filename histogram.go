@@ -0,0 +1,282 @@
+package netgearlogs
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BucketInterval is the width of the buckets a Histogram counts entries in.
+type BucketInterval int
+
+const (
+	BucketMinute BucketInterval = iota
+	BucketHour
+	BucketDay
+	// BucketHourOfWeek folds every week onto 168 hourly buckets, from Sunday 00:00 to
+	// Saturday 23:00, to show weekly patterns.
+	BucketHourOfWeek
+)
+
+// hoursPerWeek is the number of BucketHourOfWeek buckets.
+const hoursPerWeek = 7 * 24
+
+// HistogramBucket is the count of entries in one bucket.
+type HistogramBucket struct {
+	// Start is the start of the bucket. For BucketHourOfWeek it is the zero time.
+	Start time.Time
+	// Label names the bucket, e.g. "2016-02-22 18:00" or "Mon 18:00".
+	Label string
+	Count int
+}
+
+// HistogramSeries is a run of consecutive buckets for one event kind, or for every
+// kind if Kind is empty.
+type HistogramSeries struct {
+	Kind    string
+	Buckets []HistogramBucket
+}
+
+// Histogram counts entries in time buckets, either overall or per event kind. Add
+// entries with Add or Consume, then read the counts with Series.
+type Histogram struct {
+	Interval BucketInterval
+	// Location is the time zone bucket boundaries fall in. Defaults to UTC. Days are
+	// calendar days in Location, so they may be 23 or 25 hours long.
+	Location *time.Location
+	// PerKind gives each event kind its own series.
+	PerKind bool
+	// From and To, if set, limit the entries counted to from <= Time < to, and fix the
+	// range of buckets returned by Series. Otherwise the buckets span the entries seen.
+	From, To time.Time
+
+	counts   map[string]map[int64]int
+	min, max int64
+	seen     bool
+}
+
+// NewHistogram returns an empty Histogram with the given bucket interval.
+func NewHistogram(interval BucketInterval) *Histogram {
+	return &Histogram{Interval: interval}
+}
+
+func (h *Histogram) location() *time.Location {
+	if h.Location == nil {
+		return time.UTC
+	}
+	return h.Location
+}
+
+// bucketStart returns the start of the bucket holding t.
+func (h *Histogram) bucketStart(t time.Time) time.Time {
+	t = t.In(h.location())
+	switch h.Interval {
+	case BucketHour:
+		// Subtracting the offset into the hour, rather than truncating the absolute
+		// time, keeps boundaries on the hour in zones with half-hour offsets.
+		return t.Add(-time.Duration(t.Minute())*time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+	case BucketDay:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+	return t.Add(-time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+}
+
+// nextBucket returns the start of the bucket after the one starting at t.
+func (h *Histogram) nextBucket(t time.Time) time.Time {
+	switch h.Interval {
+	case BucketHour:
+		return t.Add(time.Hour)
+	case BucketDay:
+		return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+	}
+	return t.Add(time.Minute)
+}
+
+// key returns the bucket key for t: the bucket's start in Unix nanoseconds, or its
+// hour of the week.
+func (h *Histogram) key(t time.Time) int64 {
+	if h.Interval == BucketHourOfWeek {
+		t = t.In(h.location())
+		return int64(t.Weekday())*24 + int64(t.Hour())
+	}
+	return h.bucketStart(t).UnixNano()
+}
+
+// Add counts l.
+func (h *Histogram) Add(l *NetGearLog) {
+	if (!h.From.IsZero() && l.Time.Before(h.From)) || (!h.To.IsZero() && !l.Time.Before(h.To)) {
+		return
+	}
+	if h.counts == nil {
+		h.counts = make(map[string]map[int64]int)
+	}
+	kind := ""
+	if h.PerKind {
+		kind = l.Kind()
+	}
+	c, ok := h.counts[kind]
+	if !ok {
+		c = make(map[int64]int)
+		h.counts[kind] = c
+	}
+	k := h.key(l.Time)
+	c[k]++
+	if !h.seen || k < h.min {
+		h.min = k
+	}
+	if !h.seen || k > h.max {
+		h.max = k
+	}
+	h.seen = true
+}
+
+// Consume counts every entry from src, skipping lines that cannot be parsed. It
+// returns at the end of src or on the first other error.
+func (h *Histogram) Consume(src LogSource) error {
+	for {
+		l, err := src.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if _, ok := err.(*ParseError); ok {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		h.Add(l)
+	}
+}
+
+// Series returns the counts, one series per kind in kind order, or a single series if
+// PerKind is not set. Every series covers the same buckets, and buckets without
+// entries are included with a count of zero.
+func (h *Histogram) Series() []HistogramSeries {
+	type slot struct {
+		key   int64
+		start time.Time
+		label string
+	}
+	var slots []slot
+	if h.Interval == BucketHourOfWeek {
+		for k := int64(0); k < hoursPerWeek; k++ {
+			slots = append(slots, slot{key: k, label: fmt.Sprintf("%s %02d:00", time.Weekday(k / 24).String()[:3], k%24)})
+		}
+	} else {
+		var first, last time.Time
+		switch {
+		case !h.From.IsZero():
+			first = h.bucketStart(h.From)
+		case h.seen:
+			first = time.Unix(0, h.min).In(h.location())
+		}
+		switch {
+		case !h.To.IsZero():
+			last = h.bucketStart(h.To.Add(-1))
+		case h.seen:
+			last = time.Unix(0, h.max).In(h.location())
+		}
+		if first.IsZero() || last.IsZero() {
+			return nil
+		}
+		for t := first; !t.After(last); t = h.nextBucket(t) {
+			slots = append(slots, slot{key: t.UnixNano(), start: t, label: t.Format(h.labelFormat())})
+		}
+	}
+
+	var kinds []string
+	for k := range h.counts {
+		kinds = append(kinds, k)
+	}
+	if !h.PerKind && len(kinds) == 0 {
+		kinds = []string{""}
+	}
+	sort.Strings(kinds)
+	var series []HistogramSeries
+	for _, kind := range kinds {
+		s := HistogramSeries{Kind: kind, Buckets: make([]HistogramBucket, len(slots))}
+		for i, sl := range slots {
+			s.Buckets[i] = HistogramBucket{Start: sl.start, Label: sl.label, Count: h.counts[kind][sl.key]}
+		}
+		series = append(series, s)
+	}
+	return series
+}
+
+func (h *Histogram) labelFormat() string {
+	switch h.Interval {
+	case BucketHour:
+		return "2006-01-02 15:00"
+	case BucketDay:
+		return "2006-01-02"
+	}
+	return "2006-01-02 15:04"
+}
+
+// Counts returns the count in each bucket.
+func (s HistogramSeries) Counts() []int {
+	counts := make([]int, len(s.Buckets))
+	for i, b := range s.Buckets {
+		counts[i] = b.Count
+	}
+	return counts
+}
+
+// sparkLevels are the ASCII characters used by Sparkline, from lowest to highest.
+var sparkLevels = ".:-=+*#%@"
+
+// Sparkline renders the series as a line of ASCII characters, one per bucket, scaled
+// to the largest count. Empty buckets are shown as spaces.
+func (s HistogramSeries) Sparkline() string {
+	return Sparkline(s.Counts())
+}
+
+// Sparkline renders counts as a line of ASCII characters, denser for larger counts,
+// scaled to the largest count. Zero counts are shown as spaces.
+func Sparkline(counts []int) string {
+	max := 0
+	for _, c := range counts {
+		if c > max {
+			max = c
+		}
+	}
+	var b strings.Builder
+	for _, c := range counts {
+		if c <= 0 {
+			b.WriteByte(' ')
+			continue
+		}
+		level := (c*len(sparkLevels) - 1) / max
+		b.WriteByte(sparkLevels[level])
+	}
+	return b.String()
+}
+
+// WriteBars writes the series as a horizontal bar chart in plain ASCII, one bucket per
+// line, with bars of up to width '#' characters scaled to the largest count.
+func (s HistogramSeries) WriteBars(w io.Writer, width int) error {
+	if width <= 0 {
+		width = 50
+	}
+	max, labelWidth := 0, 0
+	for _, b := range s.Buckets {
+		if b.Count > max {
+			max = b.Count
+		}
+		if len(b.Label) > labelWidth {
+			labelWidth = len(b.Label)
+		}
+	}
+	for _, b := range s.Buckets {
+		n := 0
+		if max > 0 {
+			n = (b.Count*width + max - 1) / max
+		}
+		if _, err := fmt.Fprintf(w, "%-*s |%s %d\n", labelWidth, b.Label, strings.Repeat("#", n), b.Count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
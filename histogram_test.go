@@ -0,0 +1,140 @@
+package netgearlogs
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func histogramLogs(times ...string) []*NetGearLog {
+	var logs []*NetGearLog
+	for i, s := range times {
+		tm, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			panic(err)
+		}
+		kind := eventDoSAttackAckScan
+		if i%2 == 1 {
+			kind = eventDHCPIP
+		}
+		logs = append(logs, &NetGearLog{Time: tm, EventType: kind})
+	}
+	return logs
+}
+
+func TestHistogramHours(t *testing.T) {
+	h := NewHistogram(BucketHour)
+	for _, l := range histogramLogs("2016-02-22 10:05", "2016-02-22 10:59", "2016-02-22 13:00", "2016-02-22 10:30") {
+		h.Add(l)
+	}
+	series := h.Series()
+	if len(series) != 1 || series[0].Kind != "" {
+		t.Fatalf("Expected a single overall series, got %+v", series)
+	}
+	counts := series[0].Counts()
+	if len(counts) != 4 || counts[0] != 3 || counts[1] != 0 || counts[2] != 0 || counts[3] != 1 {
+		t.Errorf("Expected counts [3 0 0 1], got %v", counts)
+	}
+	if b := series[0].Buckets[1]; b.Label != "2016-02-22 11:00" || b.Start.Hour() != 11 {
+		t.Errorf("Unexpected empty bucket %+v", b)
+	}
+}
+
+func TestHistogramPerKindRange(t *testing.T) {
+	h := NewHistogram(BucketMinute)
+	h.PerKind = true
+	h.From = time.Date(2016, 2, 22, 10, 0, 0, 0, time.UTC)
+	h.To = time.Date(2016, 2, 22, 10, 5, 0, 0, time.UTC)
+	for _, l := range histogramLogs("2016-02-22 10:01", "2016-02-22 10:01", "2016-02-22 10:03", "2016-02-22 10:05", "2016-02-22 09:59") {
+		h.Add(l)
+	}
+	series := h.Series()
+	if len(series) != 2 {
+		t.Fatalf("Expected 2 series, got %d", len(series))
+	}
+	if series[0].Kind != eventDHCPIP || series[1].Kind != eventDoSAttackAckScan {
+		t.Errorf("Expected series in kind order, got %s, %s", series[0].Kind, series[1].Kind)
+	}
+	for _, s := range series {
+		if len(s.Buckets) != 5 {
+			t.Errorf("Expected 5 buckets for %s, got %d", s.Kind, len(s.Buckets))
+		}
+	}
+	if got := series[1].Counts(); got[1] != 1 || got[3] != 1 || got[0]+got[2]+got[4] != 0 {
+		t.Errorf("Unexpected DoS counts %v", got)
+	}
+}
+
+func TestHistogramTimeZone(t *testing.T) {
+	loc := time.FixedZone("UTC-5", -5*60*60)
+	h := NewHistogram(BucketDay)
+	h.Location = loc
+	for _, l := range histogramLogs("2016-02-22 03:00", "2016-02-22 06:00") {
+		h.Add(l)
+	}
+	series := h.Series()
+	if len(series[0].Buckets) != 2 {
+		t.Fatalf("Expected the entries to fall on different local days, got %+v", series[0].Buckets)
+	}
+	if b := series[0].Buckets[0]; b.Label != "2016-02-21" || b.Start.Location() != loc {
+		t.Errorf("Unexpected first bucket %+v", b)
+	}
+
+	h = NewHistogram(BucketHour)
+	h.Location = time.FixedZone("UTC+5:30", 330*60)
+	h.Add(histogramLogs("2016-02-22 03:00")[0])
+	if b := h.Series()[0].Buckets[0]; b.Label != "2016-02-22 08:00" || b.Start.Minute() != 0 {
+		t.Errorf("Expected buckets on the local hour, got %+v", b)
+	}
+}
+
+func TestHistogramHourOfWeek(t *testing.T) {
+	h := NewHistogram(BucketHourOfWeek)
+	// February 22, 2016 was a Monday.
+	for _, l := range histogramLogs("2016-02-22 18:10", "2016-02-29 18:45", "2016-02-21 00:00") {
+		h.Add(l)
+	}
+	s := h.Series()[0]
+	if len(s.Buckets) != hoursPerWeek {
+		t.Fatalf("Expected %d buckets, got %d", hoursPerWeek, len(s.Buckets))
+	}
+	if b := s.Buckets[24+18]; b.Label != "Mon 18:00" || b.Count != 2 {
+		t.Errorf("Unexpected Monday bucket %+v", b)
+	}
+	if b := s.Buckets[0]; b.Label != "Sun 00:00" || b.Count != 1 {
+		t.Errorf("Unexpected Sunday bucket %+v", b)
+	}
+}
+
+func TestHistogramLogFile(t *testing.T) {
+	h := NewHistogram(BucketDay)
+	if err := h.Consume(NewLogReader(strings.NewReader(strings.Join(readLogLines(t), "\n")))); err != nil {
+		t.Fatal(err)
+	}
+	s := h.Series()[0]
+	if s.Buckets[0].Label >= s.Buckets[len(s.Buckets)-1].Label {
+		t.Errorf("Expected buckets in time order")
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	if got := Sparkline([]int{0, 1, 4, 8}); got != " :+@" {
+		t.Errorf("Unexpected sparkline %q", got)
+	}
+	if got := Sparkline([]int{0, 0}); got != "  " {
+		t.Errorf("Unexpected sparkline %q", got)
+	}
+}
+
+func TestWriteBars(t *testing.T) {
+	s := HistogramSeries{Buckets: []HistogramBucket{{Label: "a", Count: 2}, {Label: "bb", Count: 4}, {Label: "c"}}}
+	var buf bytes.Buffer
+	if err := s.WriteBars(&buf, 10); err != nil {
+		t.Fatal(err)
+	}
+	want := "a  |##### 2\nbb |########## 4\nc  | 0\n"
+	if buf.String() != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
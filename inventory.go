@@ -0,0 +1,206 @@
+package netgearlogs
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Lease is a period in which a device was repeatedly given the same address by DHCP.
+type Lease struct {
+	IP string
+	// First and Last are the first and last DHCP entries of the period.
+	First, Last time.Time
+	// Assignments is the number of DHCP entries in the period.
+	Assignments int
+}
+
+// Device is what the log reveals about one device on the LAN, identified by its MAC
+// address.
+type Device struct {
+	// MAC is the device's address in lower case.
+	MAC string
+	// Names are the names reported for the device by Access Control entries, in the
+	// order they were first seen.
+	Names []string
+	// FirstSeen and LastSeen span every DHCP and Access Control entry for the device.
+	FirstSeen, LastSeen time.Time
+	// Leases is the device's address history, oldest first.
+	Leases []Lease
+	// Assignments is the number of DHCP entries for the device.
+	Assignments int
+	// MeanRenewal is the mean time between the device's DHCP entries, or 0 if it has
+	// fewer than two.
+	MeanRenewal time.Duration
+}
+
+// Name returns the most recently reported name of the device, or "" if none was.
+func (d *Device) Name() string {
+	if len(d.Names) == 0 {
+		return ""
+	}
+	return d.Names[len(d.Names)-1]
+}
+
+// IPAt returns the address the device held at t, or "" if it had not been given one.
+func (d *Device) IPAt(t time.Time) string {
+	i := sort.Search(len(d.Leases), func(i int) bool { return d.Leases[i].First.After(t) })
+	if i == 0 {
+		return ""
+	}
+	return d.Leases[i-1].IP
+}
+
+// deviceObservation is a DHCP assignment or an Access Control name for a device.
+type deviceObservation struct {
+	t    time.Time
+	mac  string
+	ip   string
+	name string
+}
+
+// Inventory reconstructs the devices on the LAN from DHCP IP and Access Control
+// entries. Entries may be added in any order. An Inventory is not safe for concurrent
+// use.
+type Inventory struct {
+	observations []deviceObservation
+	devices      map[string]*Device
+	// byIP holds, for each address, the DHCP assignments of it in time order.
+	byIP  map[string][]deviceObservation
+	dirty bool
+}
+
+// NewInventory returns an empty Inventory.
+func NewInventory() *Inventory {
+	return &Inventory{}
+}
+
+// Add records l if it is a DHCP IP or Access Control entry.
+func (inv *Inventory) Add(l *NetGearLog) {
+	var o deviceObservation
+	switch l.Kind() {
+	case eventDHCPIP:
+		o = deviceObservation{ip: l.FromSource}
+	case eventAccessControl:
+		if l.Device != "" && !strings.EqualFold(l.Device, "unknown") {
+			o.name = l.Device
+		}
+	default:
+		return
+	}
+	mac, ok := normalizeMAC(l.ToMACAddress)
+	if !ok {
+		return
+	}
+	o.t, o.mac = l.Time, mac
+	inv.observations = append(inv.observations, o)
+	inv.dirty = true
+}
+
+// Consume adds every entry from src, skipping lines that cannot be parsed. It returns
+// at the end of src or on the first other error.
+func (inv *Inventory) Consume(src LogSource) error {
+	for {
+		l, err := src.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if _, ok := err.(*ParseError); ok {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		inv.Add(l)
+	}
+}
+
+// build rebuilds the devices from the observations if any were added since the last
+// build.
+func (inv *Inventory) build() {
+	if !inv.dirty && inv.devices != nil {
+		return
+	}
+	sort.SliceStable(inv.observations, func(i, j int) bool {
+		return inv.observations[i].t.Before(inv.observations[j].t)
+	})
+	inv.devices = make(map[string]*Device)
+	inv.byIP = make(map[string][]deviceObservation)
+	for _, o := range inv.observations {
+		d, ok := inv.devices[o.mac]
+		if !ok {
+			d = &Device{MAC: o.mac, FirstSeen: o.t}
+			inv.devices[o.mac] = d
+		}
+		d.LastSeen = o.t
+		if o.name != "" {
+			known := false
+			for _, n := range d.Names {
+				known = known || n == o.name
+			}
+			if !known {
+				d.Names = append(d.Names, o.name)
+			}
+		}
+		if o.ip == "" {
+			continue
+		}
+		d.Assignments++
+		if n := len(d.Leases); n > 0 && d.Leases[n-1].IP == o.ip {
+			d.Leases[n-1].Last = o.t
+			d.Leases[n-1].Assignments++
+		} else {
+			d.Leases = append(d.Leases, Lease{IP: o.ip, First: o.t, Last: o.t, Assignments: 1})
+		}
+		inv.byIP[o.ip] = append(inv.byIP[o.ip], o)
+	}
+	for _, d := range inv.devices {
+		if d.Assignments > 1 {
+			d.MeanRenewal = d.Leases[len(d.Leases)-1].Last.Sub(d.Leases[0].First) / time.Duration(d.Assignments-1)
+		}
+	}
+	inv.dirty = false
+}
+
+// Devices returns every device seen, ordered by MAC address.
+func (inv *Inventory) Devices() []*Device {
+	inv.build()
+	devices := make([]*Device, 0, len(inv.devices))
+	for _, d := range inv.devices {
+		devices = append(devices, d)
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].MAC < devices[j].MAC })
+	return devices
+}
+
+// Device returns the device with the given MAC address, in any common notation, or
+// nil if it was not seen.
+func (inv *Inventory) Device(mac string) *Device {
+	inv.build()
+	m, ok := normalizeMAC(mac)
+	if !ok {
+		return nil
+	}
+	return inv.devices[m]
+}
+
+// HolderAt returns the device that held ip at t: the device most recently given ip at
+// or before t, provided it had not since been given a different address. It returns
+// nil if the log does not say who held ip at that time.
+//
+// This is the question to ask of a LAN access from remote entry, whose destination is
+// a LAN address: HolderAt(l.DestHost(), l.Time).
+func (inv *Inventory) HolderAt(ip string, t time.Time) *Device {
+	inv.build()
+	assignments := inv.byIP[ip]
+	i := sort.Search(len(assignments), func(i int) bool { return assignments[i].t.After(t) })
+	if i == 0 {
+		return nil
+	}
+	d := inv.devices[assignments[i-1].mac]
+	if d.IPAt(t) != ip {
+		return nil
+	}
+	return d
+}
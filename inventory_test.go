@@ -0,0 +1,94 @@
+package netgearlogs
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var inventoryTestLines = []string{
+	"[LAN access from remote] from 80.82.79.104:46589 to 192.168.1.9:8080, Monday, February 22, 2016 13:11:37",
+	"[DHCP IP: 192.168.1.9] to MAC address 20:7d:74:70:da:1d, Monday, February 22, 2016 12:00:00",
+	"[DHCP IP: 192.168.1.11] to MAC address b4:b6:76:bf:19:8b, Monday, February 22, 2016 11:00:00",
+	"[DHCP IP: 192.168.1.9] to MAC address b4:b6:76:bf:19:8b, Monday, February 22, 2016 10:00:00",
+	"[Access Control] Device NINJA with MAC address B4:B6:76:BF:19:8B is allowed to access the network, Monday, February 22, 2016 09:30:00",
+	"[DHCP IP: 192.168.1.9] to MAC address b4:b6:76:bf:19:8b, Monday, February 22, 2016 09:00:00",
+	"[Access Control] Device unknown with MAC address B4:B6:76:BF:19:8B is blocked to access the network, Monday, February 22, 2016 08:30:00",
+}
+
+func TestInventory(t *testing.T) {
+	inv := NewInventory()
+	if err := inv.Consume(NewSliceSource(parseLines(inventoryTestLines))); err != nil {
+		t.Fatal(err)
+	}
+	devices := inv.Devices()
+	if len(devices) != 2 {
+		t.Fatalf("Expected 2 devices, got %d", len(devices))
+	}
+	d := inv.Device("B4-B6-76-BF-19-8B")
+	if d == nil {
+		t.Fatal("Expected the device to be found by MAC address")
+	}
+	if d.Name() != "NINJA" || len(d.Names) != 1 {
+		t.Errorf("Expected the device to be named NINJA, got %v", d.Names)
+	}
+	if d.FirstSeen.Format("15:04") != "08:30" || d.LastSeen.Format("15:04") != "11:00" {
+		t.Errorf("Unexpected first and last seen %s, %s", d.FirstSeen, d.LastSeen)
+	}
+	if len(d.Leases) != 2 || d.Leases[0].IP != "192.168.1.9" || d.Leases[0].Assignments != 2 || d.Leases[1].IP != "192.168.1.11" {
+		t.Errorf("Unexpected leases %+v", d.Leases)
+	}
+	if d.Assignments != 3 || d.MeanRenewal != time.Hour {
+		t.Errorf("Expected 3 assignments an hour apart, got %d every %s", d.Assignments, d.MeanRenewal)
+	}
+	if ip := d.IPAt(time.Date(2016, 2, 22, 10, 30, 0, 0, time.UTC)); ip != "192.168.1.9" {
+		t.Errorf("Expected 192.168.1.9 at 10:30, got %q", ip)
+	}
+}
+
+func TestInventoryHolderAt(t *testing.T) {
+	inv := NewInventory()
+	for _, l := range parseLines(inventoryTestLines) {
+		inv.Add(l)
+	}
+	at := func(hour, min int) time.Time { return time.Date(2016, 2, 22, hour, min, 0, 0, time.UTC) }
+	var tests = []struct {
+		t   time.Time
+		mac string
+	}{
+		{at(8, 0), ""},
+		{at(9, 0), "b4:b6:76:bf:19:8b"},
+		{at(10, 59), "b4:b6:76:bf:19:8b"},
+		// The device moved to another address, and nobody has been given .9 since.
+		{at(11, 30), ""},
+		{at(12, 0), "20:7d:74:70:da:1d"},
+	}
+	for i, tt := range tests {
+		d := inv.HolderAt("192.168.1.9", tt.t)
+		switch {
+		case d == nil && tt.mac != "":
+			t.Errorf("%d. Expected %s at %s, got none", i, tt.mac, tt.t)
+		case d != nil && d.MAC != tt.mac:
+			t.Errorf("%d. Expected %q at %s, got %s", i, tt.mac, tt.t, d.MAC)
+		}
+	}
+
+	lan := parseLines(inventoryTestLines[:1])[0]
+	if d := inv.HolderAt(lan.DestHost(), lan.Time); d == nil || d.MAC != "20:7d:74:70:da:1d" {
+		t.Errorf("Expected the LAN access target to be 20:7d:74:70:da:1d, got %+v", d)
+	}
+}
+
+func TestInventoryLogFile(t *testing.T) {
+	inv := NewInventory()
+	if err := inv.Consume(NewLogReader(strings.NewReader(strings.Join(readLogLines(t), "\n")))); err != nil {
+		t.Fatal(err)
+	}
+	d := inv.Device("6C:71:D9:6B:7A:A0")
+	if d == nil || d.Name() != "NINJA" {
+		t.Fatalf("Expected NINJA to be attached to its DHCP MAC address, got %+v", d)
+	}
+	if len(d.Leases) == 0 || d.Leases[0].IP != "192.168.1.14" {
+		t.Errorf("Expected NINJA to hold 192.168.1.14, got %+v", d.Leases)
+	}
+}
@@ -0,0 +1,153 @@
+package netgearlogs
+
+import (
+	"bufio"
+	_ "embed" // for JSONSchema
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// JSONSchema is a JSON Schema (draft 2020-12) document describing the JSON encoding
+// of a NetGearLog, for validating output downstream.
+//
+//go:embed netgearlog.schema.json
+var JSONSchema string
+
+// netgearLogJSON is the stable JSON layout of a NetGearLog.
+type netgearLogJSON struct {
+	Time         string `json:"time"`
+	Kind         string `json:"kind"`
+	EventType    string `json:"event_type"`
+	FromSource   string `json:"from_source,omitempty"`
+	ToDest       string `json:"to_dest,omitempty"`
+	ToMACAddress string `json:"to_mac_address,omitempty"`
+	Port         int    `json:"port,omitempty"`
+	Device       string `json:"device,omitempty"`
+	Message      string `json:"message,omitempty"`
+	File         string `json:"file,omitempty"`
+	Raw          string `json:"raw,omitempty"`
+}
+
+// MarshalJSON encodes the entry with snake_case keys and an RFC 3339 timestamp. Empty
+// fields are omitted, and the entry's Kind is included for convenience.
+func (l NetGearLog) MarshalJSON() ([]byte, error) {
+	return json.Marshal(netgearLogJSON{
+		Time:         l.Time.Format(time.RFC3339Nano),
+		Kind:         l.Kind(),
+		EventType:    l.EventType,
+		FromSource:   l.FromSource,
+		ToDest:       l.ToDest,
+		ToMACAddress: l.ToMACAddress,
+		Port:         l.Port,
+		Device:       l.Device,
+		Message:      l.Message,
+		File:         l.File,
+		Raw:          l.Raw,
+	})
+}
+
+// UnmarshalJSON decodes an entry encoded by MarshalJSON. The kind is derived from the
+// event type, so it is not read back.
+func (l *NetGearLog) UnmarshalJSON(data []byte) error {
+	var j netgearLogJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	t, err := time.Parse(time.RFC3339Nano, j.Time)
+	if err != nil {
+		return fmt.Errorf("invalid time %q: %s", j.Time, err)
+	}
+	*l = NetGearLog{
+		Time:         t,
+		FromSource:   j.FromSource,
+		ToDest:       j.ToDest,
+		ToMACAddress: j.ToMACAddress,
+		EventType:    j.EventType,
+		Port:         j.Port,
+		Device:       j.Device,
+		Message:      j.Message,
+		Raw:          j.Raw,
+		File:         j.File,
+	}
+	return nil
+}
+
+// NDJSONEncoder writes entries as newline-delimited JSON, one object per line.
+type NDJSONEncoder struct {
+	w *bufio.Writer
+}
+
+// NewNDJSONEncoder returns an encoder writing to w. Flush must be called when done.
+func NewNDJSONEncoder(w io.Writer) *NDJSONEncoder {
+	return &NDJSONEncoder{w: bufio.NewWriter(w)}
+}
+
+// Encode writes a single entry.
+func (e *NDJSONEncoder) Encode(l *NetGearLog) error {
+	b, err := l.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	if _, err := e.w.Write(b); err != nil {
+		return err
+	}
+	return e.w.WriteByte('\n')
+}
+
+// EncodeAll writes every entry from src, stopping at the first error other than a
+// *ParseError, which is skipped.
+func (e *NDJSONEncoder) EncodeAll(src LogSource) error {
+	for {
+		l, err := src.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if _, ok := err.(*ParseError); ok {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := e.Encode(l); err != nil {
+			return err
+		}
+	}
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (e *NDJSONEncoder) Flush() error {
+	return e.w.Flush()
+}
+
+// NDJSONDecoder is a LogSource reading entries written by an NDJSONEncoder.
+type NDJSONDecoder struct {
+	s    *bufio.Scanner
+	line int
+}
+
+// NewNDJSONDecoder returns a decoder reading from r.
+func NewNDJSONDecoder(r io.Reader) *NDJSONDecoder {
+	return &NDJSONDecoder{s: bufio.NewScanner(r)}
+}
+
+// Next decodes the next entry. Malformed lines are reported as *ParseError.
+func (d *NDJSONDecoder) Next() (*NetGearLog, error) {
+	for d.s.Scan() {
+		d.line++
+		b := d.s.Bytes()
+		if len(b) == 0 {
+			continue
+		}
+		l := &NetGearLog{}
+		if err := l.UnmarshalJSON(b); err != nil {
+			return nil, &ParseError{Line: d.line, Text: string(b), Err: err}
+		}
+		return l, nil
+	}
+	if err := d.s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
@@ -0,0 +1,110 @@
+package netgearlogs
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	line := "[DoS Attack: RST Scan] from source: 167.114.119.146, port 25583, Monday, February 22, 2016 16:46:16"
+	l, err := ParseNetGearLogLine(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"time":"2016-02-22T16:46:16Z","kind":"DoS Attack: RST Scan","event_type":"DoS Attack: RST Scan","from_source":"167.114.119.146","port":25583,"raw":"` + line + `"}`
+	if string(b) != expected {
+		t.Errorf("Expected %s, got %s", expected, b)
+	}
+}
+
+func TestNDJSONRoundTrip(t *testing.T) {
+	f, err := os.Open("log.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var logs []*NetGearLog
+	r := NewLogReader(f)
+	r.file = "log.txt"
+	for {
+		l, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			logs = append(logs, l)
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := NewNDJSONEncoder(&buf)
+	if err := enc.EncodeAll(NewSliceSource(logs)); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(buf.String(), "\n"); n != len(logs) {
+		t.Fatalf("Expected %d lines, got %d", len(logs), n)
+	}
+
+	dec := NewNDJSONDecoder(&buf)
+	for i, expected := range logs {
+		l, err := dec.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(expected, l) {
+			t.Fatalf("Entry %d did not round trip: expected %+v, got %+v", i, expected, l)
+		}
+	}
+	if _, err := dec.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+}
+
+func TestNDJSONDecoderError(t *testing.T) {
+	dec := NewNDJSONDecoder(strings.NewReader("{\"time\":\"yesterday\"}\n"))
+	if _, err := dec.Next(); err == nil {
+		t.Error("Expected an error for an invalid time")
+	} else if _, ok := err.(*ParseError); !ok {
+		t.Errorf("Expected *ParseError, got %T", err)
+	}
+}
+
+func TestJSONSchemaMatchesEncoding(t *testing.T) {
+	var schema struct {
+		Properties map[string]interface{} `json:"properties"`
+		Required   []string               `json:"required"`
+	}
+	if err := json.Unmarshal([]byte(JSONSchema), &schema); err != nil {
+		t.Fatal(err)
+	}
+	tags := make(map[string]string)
+	fields := reflect.TypeOf(netgearLogJSON{})
+	for i := 0; i < fields.NumField(); i++ {
+		tag := fields.Field(i).Tag.Get("json")
+		key := strings.Split(tag, ",")[0]
+		tags[key] = tag
+		if _, ok := schema.Properties[key]; !ok {
+			t.Errorf("Schema is missing property %s", key)
+		}
+	}
+	if len(schema.Properties) != len(tags) {
+		t.Errorf("Schema has %d properties, encoding has %d", len(schema.Properties), len(tags))
+	}
+	for _, key := range schema.Required {
+		if tag, ok := tags[key]; !ok || strings.Contains(tag, "omitempty") {
+			t.Errorf("Required property %s may be omitted", key)
+		}
+	}
+}
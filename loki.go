@@ -0,0 +1,135 @@
+package netgearlogs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LokiExporter sends entries to Grafana Loki through its push API. Each entry becomes a
+// log line labelled with the job, the router name and the event kind; the set of
+// kinds is fixed, so label cardinality stays bounded. Entries are batched and retried
+// according to BatchOptions.
+type LokiExporter struct {
+	// URL is the base URL of Loki, e.g. http://localhost:3100.
+	URL string
+	// Router names the router; it is sent as the router label.
+	Router string
+	// Job is sent as the job label. Defaults to "netgear".
+	Job string
+	// TenantID, if set, is sent as the X-Scope-OrgID header.
+	TenantID string
+	// Client is the HTTP client used; http.DefaultClient if nil.
+	Client *http.Client
+
+	b *batcher
+}
+
+// NewLokiExporter returns an exporter pushing to the Loki instance at url, with
+// entries attributed to the named router.
+func NewLokiExporter(url, router string, opts BatchOptions) *LokiExporter {
+	e := &LokiExporter{URL: url, Router: router, Job: "netgear"}
+	e.b = newBatcher(opts, e.send)
+	return e
+}
+
+// Export queues l. Full batches are sent in the background, so Export does not wait
+// on the destination.
+func (e *LokiExporter) Export(l *NetGearLog) error { return e.b.add(l) }
+
+// Flush sends every queued entry.
+func (e *LokiExporter) Flush() error { return e.b.flush() }
+
+// Dropped returns the number of entries discarded because the buffer overflowed or
+// Loki rejected them.
+func (e *LokiExporter) Dropped() int { return e.b.droppedCount() }
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+type lokiPush struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+// LokiPushRequest builds the push API body for logs, grouping entries into one stream
+// per label set. Streams are ordered by kind, and entries within a stream by time, as
+// Loki requires.
+func LokiPushRequest(logs []*NetGearLog, job, router string) ([]byte, error) {
+	sorted := make([]*NetGearLog, 0, len(logs))
+	for _, l := range logs {
+		if l != nil {
+			sorted = append(sorted, l)
+		}
+	}
+	SortLogs(sorted)
+	streams := make(map[string]*lokiStream)
+	var kinds []string
+	for _, l := range sorted {
+		kind := l.Kind()
+		s, ok := streams[kind]
+		if !ok {
+			s = &lokiStream{Stream: map[string]string{"job": job, "kind": kind}}
+			if router != "" {
+				s.Stream["router"] = router
+			}
+			streams[kind] = s
+			kinds = append(kinds, kind)
+		}
+		line := l.Raw
+		if line == "" {
+			line = l.String()
+		}
+		s.Values = append(s.Values, [2]string{strconv.FormatInt(l.Time.UnixNano(), 10), line})
+	}
+	sort.Strings(kinds)
+	push := lokiPush{}
+	for _, k := range kinds {
+		push.Streams = append(push.Streams, *streams[k])
+	}
+	return json.Marshal(push)
+}
+
+func (e *LokiExporter) send(batch []*NetGearLog) error {
+	job := e.Job
+	if job == "" {
+		job = "netgear"
+	}
+	body, err := LokiPushRequest(batch, job, e.Router)
+	if err != nil {
+		return permanentError{err}
+	}
+	req, err := http.NewRequest("POST", strings.TrimRight(e.URL, "/")+"/loki/api/v1/push", bytes.NewReader(body))
+	if err != nil {
+		return permanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.TenantID != "" {
+		req.Header.Set("X-Scope-OrgID", e.TenantID)
+	}
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("loki push failed: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	// Rate limiting and server errors are worth retrying; other client errors are not.
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode/100 == 5 {
+		return err
+	}
+	return permanentError{err}
+}
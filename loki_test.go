@@ -0,0 +1,78 @@
+package netgearlogs
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLokiExporter(t *testing.T) {
+	var pushes []lokiPush
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			http.Error(w, "ingester not ready", http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path != "/loki/api/v1/push" || r.Header.Get("X-Scope-OrgID") != "home" {
+			t.Errorf("Unexpected request to %s, tenant %q", r.URL.Path, r.Header.Get("X-Scope-OrgID"))
+		}
+		var p lokiPush
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			t.Error(err)
+		}
+		pushes = append(pushes, p)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	e := NewLokiExporter(srv.URL, "wndr4300", BatchOptions{BatchSize: 3})
+	e.TenantID = "home"
+	e.b.sleep = func(time.Duration) {}
+	logs := parseLines([]string{
+		"[DoS Attack: RST Scan] from source: 167.114.119.146, port 25583, Monday, February 22, 2016 16:46:16",
+		"[DHCP IP: 192.168.1.10] to MAC address 10:a5:d0:cd:fc:19, Monday, February 22, 2016 16:35:52",
+		"[DoS Attack: RST Scan] from source: 94.23.182.44, port 80, Monday, February 22, 2016 16:28:10",
+		"[admin login] from source 192.168.1.6, Tuesday, February 23, 2016 19:06:07",
+	})
+	for _, l := range logs {
+		if err := e.Export(l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 3 || len(pushes) != 2 {
+		t.Fatalf("Expected one retry and two pushes, got %d attempts and %d pushes", attempts, len(pushes))
+	}
+	first := pushes[0].Streams
+	if len(first) != 2 {
+		t.Fatalf("Expected 2 streams, got %d", len(first))
+	}
+	dhcp, dos := first[0], first[1]
+	if dhcp.Stream["kind"] != eventDHCPIP || dos.Stream["kind"] != eventDoSAttackRstScan || dos.Stream["router"] != "wndr4300" || dos.Stream["job"] != "netgear" {
+		t.Errorf("Unexpected labels %v and %v", dhcp.Stream, dos.Stream)
+	}
+	if len(dos.Values) != 2 || dos.Values[0][0] != "1456158490000000000" || dos.Values[0][1] != logs[2].Raw {
+		t.Errorf("Expected DoS entries in time order, got %v", dos.Values)
+	}
+}
+
+func TestLokiExporterClientError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "entry out of order", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+	e := NewLokiExporter(srv.URL, "wndr4300", BatchOptions{})
+	e.Export(&NetGearLog{EventType: eventAdminLogin})
+	if err := e.Flush(); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if e.Dropped() != 1 {
+		t.Errorf("Expected rejected entry to be dropped, got %d", e.Dropped())
+	}
+}
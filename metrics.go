@@ -0,0 +1,203 @@
+package netgearlogs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxPortLabels is the number of distinct DoS ports given their own label value
+// before further ports are counted under "other".
+const DefaultMaxPortLabels = 32
+
+// Metrics counts router events for Prometheus. Feed it entries with Observe, or a whole
+// stream with Consume, and serve it as the /metrics handler; it writes the text
+// exposition format.
+//
+// Label values come only from fixed sets (event kinds, DoS attack types) or from a
+// capped set of ports, never from addresses, so an attacker cannot grow the number of
+// series without bound.
+type Metrics struct {
+	// MaxPortLabels caps the distinct port label values; see DefaultMaxPortLabels.
+	MaxPortLabels int
+
+	mu            sync.Mutex
+	events        map[string]uint64
+	dos           map[[2]string]uint64
+	ports         map[string]bool
+	parseErrors   uint64
+	wanIP         string
+	wanIPChanges  uint64
+	lastConnected time.Time
+	now           func() time.Time
+}
+
+// NewMetrics returns an empty Metrics.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		MaxPortLabels: DefaultMaxPortLabels,
+		events:        make(map[string]uint64),
+		dos:           make(map[[2]string]uint64),
+		ports:         make(map[string]bool),
+		now:           time.Now,
+	}
+}
+
+// Observe counts a single entry.
+func (m *Metrics) Observe(l *NetGearLog) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kind := l.Kind()
+	if _, ok := siemEvents[kind]; !ok {
+		kind = "other"
+	}
+	m.events[kind]++
+	if strings.HasPrefix(kind, eventDoSAttack) {
+		attack := strings.TrimSpace(strings.TrimPrefix(kind, eventDoSAttack+":"))
+		m.dos[[2]string{attack, m.portLabel(l.Port)}]++
+	}
+	if kind == eventInternetConnected {
+		if m.wanIP != "" && m.wanIP != l.FromSource {
+			m.wanIPChanges++
+		}
+		m.wanIP = l.FromSource
+		if l.Time.After(m.lastConnected) {
+			m.lastConnected = l.Time
+		}
+	}
+}
+
+// ObserveParseError counts a line that could not be parsed.
+func (m *Metrics) ObserveParseError() {
+	m.mu.Lock()
+	m.parseErrors++
+	m.mu.Unlock()
+}
+
+// Consume observes every entry from src, counting *ParseError results as parse errors.
+// It returns at the end of src or on the first other error.
+func (m *Metrics) Consume(src LogSource) error {
+	for {
+		l, err := src.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if _, ok := err.(*ParseError); ok {
+			m.ObserveParseError()
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		m.Observe(l)
+	}
+}
+
+// portLabel returns the label value for a DoS port, assigning new ports a label of
+// their own until MaxPortLabels is reached.
+func (m *Metrics) portLabel(port int) string {
+	if port == 0 {
+		return "none"
+	}
+	p := strconv.Itoa(port)
+	if m.ports[p] {
+		return p
+	}
+	max := m.MaxPortLabels
+	if max <= 0 {
+		max = DefaultMaxPortLabels
+	}
+	if len(m.ports) < max {
+		m.ports[p] = true
+		return p
+	}
+	return "other"
+}
+
+// ServeHTTP writes the current metrics in the Prometheus text exposition format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo writes the current metrics in the Prometheus text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+
+	fmt.Fprintln(cw, "# HELP netgear_events_total Router log entries by event kind.")
+	fmt.Fprintln(cw, "# TYPE netgear_events_total counter")
+	var kinds []string
+	for k := range m.events {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	for _, k := range kinds {
+		fmt.Fprintf(cw, "netgear_events_total{kind=\"%s\"} %d\n", escapeLabel(k), m.events[k])
+	}
+
+	fmt.Fprintln(cw, "# HELP netgear_dos_attacks_total DoS attacks reported by the router, by attack type and port.")
+	fmt.Fprintln(cw, "# TYPE netgear_dos_attacks_total counter")
+	var dos [][2]string
+	for k := range m.dos {
+		dos = append(dos, k)
+	}
+	sort.Slice(dos, func(i, j int) bool {
+		if dos[i][0] != dos[j][0] {
+			return dos[i][0] < dos[j][0]
+		}
+		return dos[i][1] < dos[j][1]
+	})
+	for _, k := range dos {
+		fmt.Fprintf(cw, "netgear_dos_attacks_total{type=\"%s\",port=\"%s\"} %d\n", escapeLabel(k[0]), escapeLabel(k[1]), m.dos[k])
+	}
+
+	fmt.Fprintln(cw, "# HELP netgear_parse_errors_total Log lines that could not be parsed.")
+	fmt.Fprintln(cw, "# TYPE netgear_parse_errors_total counter")
+	fmt.Fprintf(cw, "netgear_parse_errors_total %d\n", m.parseErrors)
+
+	fmt.Fprintln(cw, "# HELP netgear_wan_ip_changes Times the WAN address changed between Internet connected entries.")
+	fmt.Fprintln(cw, "# TYPE netgear_wan_ip_changes gauge")
+	fmt.Fprintf(cw, "netgear_wan_ip_changes %d\n", m.wanIPChanges)
+
+	if !m.lastConnected.IsZero() {
+		fmt.Fprintln(cw, "# HELP netgear_last_internet_connected_timestamp_seconds Time of the latest Internet connected entry.")
+		fmt.Fprintln(cw, "# TYPE netgear_last_internet_connected_timestamp_seconds gauge")
+		fmt.Fprintf(cw, "netgear_last_internet_connected_timestamp_seconds %d\n", m.lastConnected.Unix())
+		fmt.Fprintln(cw, "# HELP netgear_seconds_since_internet_connected Seconds since the latest Internet connected entry.")
+		fmt.Fprintln(cw, "# TYPE netgear_seconds_since_internet_connected gauge")
+		fmt.Fprintf(cw, "netgear_seconds_since_internet_connected %g\n", m.now().Sub(m.lastConnected).Seconds())
+	}
+	if err := cw.w.Flush(); err != nil && cw.err == nil {
+		cw.err = err
+	}
+	return cw.n, cw.err
+}
+
+// escapeLabel escapes a label value for the text exposition format.
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
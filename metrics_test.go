@@ -0,0 +1,89 @@
+package netgearlogs
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsExposition(t *testing.T) {
+	m := NewMetrics()
+	m.now = func() time.Time { return time.Date(2016, 2, 22, 17, 12, 59, 0, time.UTC) }
+	src := NewLogReader(strings.NewReader(strings.Join([]string{
+		"[Internet connected] IP address: 96.37.90.24, Monday, February 22, 2016 17:02:59",
+		"[DoS Attack: SYN/ACK Scan] from source: 37.59.134.139, port 80, Monday, February 22, 2016 18:10:18",
+		"[DoS Attack: SYN/ACK Scan] from source: 94.23.182.44, port 80, Monday, February 22, 2016 16:28:10",
+		"[DoS Attack: ICMP Scan] from source: 208.100.26.236, Sunday, February 21, 2016 08:07:57",
+		"not a log line",
+		"[Internet connected] IP address: 96.37.90.25, Monday, February 22, 2016 12:00:00",
+	}, "\n")))
+	if err := m.Consume(src); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Unexpected content type %s", ct)
+	}
+	body := rec.Body.String()
+	for _, line := range []string{
+		`netgear_events_total{kind="DoS Attack: SYN/ACK Scan"} 2`,
+		`netgear_events_total{kind="Internet connected"} 2`,
+		`netgear_dos_attacks_total{type="SYN/ACK Scan",port="80"} 2`,
+		`netgear_dos_attacks_total{type="ICMP Scan",port="none"} 1`,
+		`netgear_parse_errors_total 1`,
+		`netgear_wan_ip_changes 1`,
+		`netgear_last_internet_connected_timestamp_seconds 1456160579`,
+		`netgear_seconds_since_internet_connected 600`,
+		`# TYPE netgear_events_total counter`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("Expected %q in:\n%s", line, body)
+		}
+	}
+}
+
+func TestMetricsBoundedPorts(t *testing.T) {
+	m := NewMetrics()
+	m.MaxPortLabels = 3
+	for port := 1; port <= 10; port++ {
+		m.Observe(&NetGearLog{EventType: eventDoSAttackRstScan, FromSource: "10.0.0.1", Port: port})
+	}
+	m.Observe(&NetGearLog{EventType: "something new"})
+	var buf bytes.Buffer
+	m.WriteTo(&buf)
+	body := buf.String()
+	if n := strings.Count(body, "netgear_dos_attacks_total{"); n != 4 {
+		t.Errorf("Expected 3 ports plus other, got %d series:\n%s", n, body)
+	}
+	if !strings.Contains(body, `port="other"} 7`) || !strings.Contains(body, `kind="other"} 1`) {
+		t.Errorf("Expected overflow series:\n%s", body)
+	}
+	if strings.Contains(body, "10.0.0.1") {
+		t.Error("Addresses must never be used as labels")
+	}
+}
+
+func TestMetricsLogFile(t *testing.T) {
+	f, err := os.Open("log.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	m := NewMetrics()
+	if err := m.Consume(NewLogReader(f)); err != nil {
+		t.Fatal(err)
+	}
+	n, err := m.WriteTo(io.Discard)
+	if err != nil || n == 0 {
+		t.Errorf("Expected metrics to be written, got %d bytes, %v", n, err)
+	}
+	if m.parseErrors != 0 {
+		t.Errorf("Expected no parse errors, got %d", m.parseErrors)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -20,10 +21,76 @@ type NetGearLog struct {
 	ToDest       string
 	ToMACAddress string
 	EventType    string
+	// Port is the port the router reported alongside FromSource, if any.
+	Port int
+	// Device is the device name reported by Access Control entries.
+	Device string
+	// Message is free-form text the router included with the event, such as the
+	// reason an email failed to send.
+	Message string
+	// Raw is the log line the entry was parsed from.
+	Raw string
+	// File is the file the entry was read from, when it came from a LogSet.
+	File string
+}
+
+// Kind returns the general kind of event the entry records. This is its EventType
+// without any per-entry detail, such as whether an Access Control device was allowed
+// or blocked, or whether a Dynamic DNS registration succeeded.
+func (l *NetGearLog) Kind() string {
+	for _, k := range []string{eventAccessControl, eventDynamicDNS} {
+		if strings.HasPrefix(l.EventType, k) {
+			return k
+		}
+	}
+	return l.EventType
+}
+
+// SourceIP returns the address in FromSource without any port.
+func (l *NetGearLog) SourceIP() string {
+	host, _ := splitHostPort(l.FromSource)
+	return host
+}
+
+// SourcePort returns the port reported for the source of the entry, or 0 if there is none.
+func (l *NetGearLog) SourcePort() int {
+	if l.Port != 0 {
+		return l.Port
+	}
+	_, port := splitHostPort(l.FromSource)
+	return port
+}
+
+// DestHost returns the address or host name in ToDest without any port.
+func (l *NetGearLog) DestHost() string {
+	host, _ := splitHostPort(l.ToDest)
+	return host
+}
+
+// DestPort returns the port in ToDest, or 0 if there is none.
+func (l *NetGearLog) DestPort() int {
+	_, port := splitHostPort(l.ToDest)
+	return port
+}
+
+// splitHostPort splits an "address:port" pair as found in LAN access entries. Anything
+// else, including MAC addresses, is returned whole with a port of 0.
+func splitHostPort(s string) (string, int) {
+	if strings.Count(s, ":") != 1 {
+		return s, 0
+	}
+	i := strings.Index(s, ":")
+	port, err := strconv.Atoi(s[i+1:])
+	if err != nil {
+		return s, 0
+	}
+	return s[:i], port
 }
 
 const (
 	netgearLogDateFmt = "Monday, January 2, 2006 15:04:05"
+	// Newer firmware leaves out the space between the day and the year.
+	netgearLogDateFmtNoSpace = "Monday, January 2,2006 15:04:05"
 
 	eventDoSAttackSynAckScan    = "DoS Attack: SYN/ACK Scan"
 	eventDoSAttackRstScan       = "DoS Attack: RST Scan"
@@ -46,6 +113,9 @@ const (
 	eventInternetConnected      = "Internet connected"
 	eventAdminLogin             = "admin login"
 	eventEmailSent              = "email sent to"
+	eventEmailFailed            = "email failed"
+	eventSiteAllowed            = "Site allowed"
+	eventSiteBlocked            = "Site blocked"
 )
 
 // ParseNetGearLog takes an `io.Reader` type and parses the entirety of it into a slice of `NetGearLog` entries.
@@ -69,6 +139,15 @@ func ParseNetGearLog(r io.Reader) ([]*NetGearLog, map[string]error) {
 
 // ParseNetGearLogLine takes a log entry line and converts it into either a NetGearLog entry or an error if one occurs.
 func ParseNetGearLogLine(line string) (*NetGearLog, error) {
+	log, err := parseLine(line)
+	if err != nil {
+		return nil, err
+	}
+	log.Raw = line
+	return log, nil
+}
+
+func parseLine(line string) (*NetGearLog, error) {
 	switch {
 	case strings.Contains(line, eventDoSAttackSynAckScan):
 		return dosAttack(line, eventDoSAttackSynAckScan)
@@ -104,8 +183,14 @@ func ParseNetGearLogLine(line string) (*NetGearLog, error) {
 		return adminLogin(line)
 	case strings.Contains(line, eventEmailSent):
 		return emailSent(line)
+	case strings.Contains(line, eventEmailFailed):
+		return emailFailed(line)
 	case strings.Contains(line, eventDynamicDNS):
 		return dynamicDNS(line)
+	case strings.Contains(line, eventSiteAllowed):
+		return siteAccess(line, eventSiteAllowed)
+	case strings.Contains(line, eventSiteBlocked):
+		return siteAccess(line, eventSiteBlocked)
 	}
 	return nil, fmt.Errorf("Log Line Not Parseable: \n%s", line)
 }
@@ -118,6 +203,9 @@ func parseTimeString(pieces []string) (time.Time, error) {
 	t := strings.Join(pieces, " ")
 	tm, err := time.Parse(netgearLogDateFmt, t)
 	if err != nil {
+		if tm, nerr := time.Parse(netgearLogDateFmtNoSpace, t); nerr == nil {
+			return tm, nil
+		}
 		return time.Now(), err
 	}
 	return tm, nil
@@ -130,9 +218,14 @@ func dosAttack(line, eventType string) (*NetGearLog, error) {
 		return nil, terr
 	}
 	s := trimStrings(pieces[6])
+	port, err := strconv.Atoi(trimStrings(pieces[8]))
+	if err != nil {
+		return nil, err
+	}
 	log := &NetGearLog{
 		Time:       t,
 		FromSource: s,
+		Port:       port,
 		EventType:  eventType,
 	}
 	return log, nil
@@ -274,6 +367,7 @@ func accessControl(line string) (*NetGearLog, error) {
 		EventType:    eventAccessControl + " " + blk,
 		Time:         t,
 		ToMACAddress: mac,
+		Device:       pieces[3],
 	}
 	return log, nil
 }
@@ -316,9 +410,58 @@ func dynamicDNS(line string) (*NetGearLog, error) {
 	}
 	dest := strings.Trim(pieces[4], " ")
 	log := &NetGearLog{
-		EventType: eventDynamicDNS + " registration " + pieces[6],
+		EventType: eventDynamicDNS + " registration " + trimStrings(pieces[6]),
 		Time:      t,
 		ToDest:    dest,
 	}
 	return log, nil
 }
+
+func siteAccess(line, eventType string) (*NetGearLog, error) {
+	// The site name runs from the event type to the closing bracket and may itself
+	// hold spaces.
+	start := strings.Index(line, eventType+":")
+	end := strings.LastIndex(line, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("Log Line Not Parseable: \n%s", line)
+	}
+	site := strings.TrimSpace(line[start+len(eventType)+1 : end])
+	pieces := strings.Fields(line[end+1:])
+	if len(pieces) < 4 {
+		return nil, fmt.Errorf("Length of pieces is %d, %+v", len(pieces), pieces)
+	}
+	t, err := parseTimeString(pieces[3:])
+	if err != nil {
+		return nil, err
+	}
+	log := &NetGearLog{
+		EventType:  eventType,
+		Time:       t,
+		FromSource: trimStrings(pieces[2]),
+		ToDest:     site,
+	}
+	return log, nil
+}
+
+func emailFailed(line string) (*NetGearLog, error) {
+	msg := strings.TrimSpace(line[strings.Index(line, "]")+1:])
+	// The message runs up to the comma before the timestamp, which may itself hold
+	// three or four fields depending on the firmware's date format.
+	for n := 4; n <= 5; n++ {
+		pieces := strings.Fields(msg)
+		if len(pieces) <= n {
+			break
+		}
+		t, err := parseTimeString(pieces[len(pieces)-n:])
+		if err != nil {
+			continue
+		}
+		log := &NetGearLog{
+			EventType: eventEmailFailed,
+			Time:      t,
+			Message:   trimStrings(strings.Join(pieces[:len(pieces)-n], " ")),
+		}
+		return log, nil
+	}
+	return nil, fmt.Errorf("Log Line Not Parseable: \n%s", line)
+}
@@ -3,6 +3,8 @@ package netgearlogs
 import (
 "testing"
 "os"
+"strings"
+"time"
 )
 
 func TestDoSAttackSynAckLogLine(t *testing.T) {
@@ -158,6 +160,138 @@ func TestDynamicDNS(t *testing.T) {
 	}
 }
 
+func TestSiteAllowed(t *testing.T) {
+	line := "[Site allowed: fonts.googleapis.com] from source 192.168.1.21, Wednesday, May 16,2018 08:59:58"
+	l, err := ParseNetGearLogLine(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.ToDest != "fonts.googleapis.com" || l.FromSource != "192.168.1.21" {
+		t.Errorf("Unexpected site or source: %+v", l)
+	}
+	if l.Time.Year() != 2018 {
+		t.Errorf("Expected 2018, got %s", l.Time)
+	}
+}
+
+func TestAddressHelpers(t *testing.T) {
+	dos, err := ParseNetGearLogLine("[DoS Attack: SYN/ACK Scan] from source: 37.59.134.139, port 80, Monday, February 22, 2016 18:10:18")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dos.SourceIP() != "37.59.134.139" || dos.SourcePort() != 80 {
+		t.Errorf("Expected 37.59.134.139 port 80, got %s port %d", dos.SourceIP(), dos.SourcePort())
+	}
+	lan, err := ParseNetGearLogLine("[LAN access from remote] from 80.82.79.104:46589 to 192.168.1.9:8080, Monday, February 22, 2016 13:11:37")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lan.SourceIP() != "80.82.79.104" || lan.SourcePort() != 46589 {
+		t.Errorf("Expected 80.82.79.104 port 46589, got %s port %d", lan.SourceIP(), lan.SourcePort())
+	}
+	if lan.DestHost() != "192.168.1.9" || lan.DestPort() != 8080 {
+		t.Errorf("Expected 192.168.1.9 port 8080, got %s port %d", lan.DestHost(), lan.DestPort())
+	}
+	if lan.Raw == "" {
+		t.Error("Expected the raw line to be kept")
+	}
+}
+
+func TestSiteBlocked(t *testing.T) {
+	line := "[Site blocked: ads.example.com] from source 192.168.1.21, Wednesday, May 16,2018 08:59:58"
+	l, err := ParseNetGearLogLine(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.EventType != "Site blocked" || l.ToDest != "ads.example.com" || l.FromSource != "192.168.1.21" || l.Raw != line {
+		t.Errorf("Unexpected site blocked entry %+v", l)
+	}
+	if _, err := ParseNetGearLogLine("[Site blocked: ads.example.com] from source"); err == nil {
+		t.Error("Expected a truncated site line to fail")
+	}
+
+	// A site name holding spaces must not shift the other fields.
+	l, err = ParseNetGearLogLine("[Site blocked: free online games] from source 192.168.1.21, Wednesday, May 16,2018 09:00:01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.ToDest != "free online games" || l.FromSource != "192.168.1.21" || l.Time.Second() != 1 {
+		t.Errorf("Unexpected site blocked entry with spaces %+v", l)
+	}
+}
+
+func TestDoSAttackPort(t *testing.T) {
+	l, err := ParseNetGearLogLine("[DoS Attack: RST Scan] from source: 167.114.119.146, port 25583, Wednesday, May 16,2018 16:46:16")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Port != 25583 || l.FromSource != "167.114.119.146" || l.Time.Year() != 2018 {
+		t.Errorf("Unexpected DoS entry %+v", l)
+	}
+	if _, err := ParseNetGearLogLine("[DoS Attack: RST Scan] from source: 167.114.119.146, port http, Monday, February 22, 2016 16:46:16"); err == nil {
+		t.Error("Expected a non-numeric port to fail")
+	}
+}
+
+func TestParseTimeStringLayouts(t *testing.T) {
+	for _, s := range []string{"Wednesday, May 16, 2018 08:59:58", "Wednesday, May 16,2018 08:59:58"} {
+		tm, err := parseTimeString(strings.Fields(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !tm.Equal(time.Date(2018, 5, 16, 8, 59, 58, 0, time.UTC)) {
+			t.Errorf("%s: got %s", s, tm)
+		}
+	}
+	if _, err := parseTimeString([]string{"Wednesday,", "16", "May"}); err == nil {
+		t.Error("Expected an unknown layout to fail")
+	}
+}
+
+func TestAccessControlDevice(t *testing.T) {
+	line := "[Access Control] Device NINJA with MAC address 6C:71:D9:6B:7A:A0 is allowed to access the network, Wednesday, February 17, 2016 11:15:09"
+	l, err := ParseNetGearLogLine(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Device != "NINJA" || l.ToMACAddress != "6C:71:D9:6B:7A:A0" || l.EventType != "Access Control allowed" {
+		t.Errorf("Unexpected access control entry %+v", l)
+	}
+}
+
+func TestDynamicDNSEventType(t *testing.T) {
+	for line, expected := range map[string]string{
+		"[Dynamic DNS] host name klauer.mynetgear.com registeration successful, Thursday, February 18, 2016 21:12:16": "Dynamic DNS registration successful",
+		"[Dynamic DNS] host name klauer.mynetgear.com registeration failure, Thursday, February 18, 2016 21:12:06":    "Dynamic DNS registration failure",
+	} {
+		l, err := ParseNetGearLogLine(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l.EventType != expected || l.ToDest != "klauer.mynetgear.com" {
+			t.Errorf("Expected %q without the trailing comma, got %q", expected, l.EventType)
+		}
+	}
+}
+
+func TestEmailFailed(t *testing.T) {
+	for _, line := range []string{
+		"[email failed] internet connection is dropped, Wednesday, May 16,2018 09:01:11",
+		"[email failed] internet connection is dropped, Wednesday, February 17, 2016 09:01:11",
+	} {
+		l, err := ParseNetGearLogLine(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l.EventType != "email failed" || l.Message != "internet connection is dropped" || l.Time.Hour() != 9 {
+			t.Errorf("Unexpected email failed entry %+v", l)
+		}
+	}
+	if _, err := ParseNetGearLogLine("[email failed] no date here"); err == nil {
+		t.Error("Expected an email failed line without a date to fail")
+	}
+}
+
 func TestLogFile(t *testing.T) {
 	f, err := os.Open("log.txt")
 	if err != nil {
@@ -0,0 +1,488 @@
+package netgearlogs
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Notifier delivers an alert to a person or another system.
+type Notifier interface {
+	Notify(a Alert) error
+}
+
+// Defaults for DispatchOptions.
+const (
+	DefaultDispatchRateLimit    = 10
+	DefaultDispatchRateInterval = time.Hour
+	DefaultDispatchDedupWindow  = time.Hour
+	DefaultDispatchQueueSize    = 1000
+	DefaultDispatchMaxAttempts  = 5
+	DefaultDispatchBackoff      = time.Minute
+	DefaultDispatchMaxBackoff   = time.Hour
+)
+
+// DispatchOptions configure a Dispatcher. Zero fields take the defaults.
+type DispatchOptions struct {
+	// RateLimit is the most alerts a rule may raise per RateInterval; the rest are
+	// suppressed.
+	RateLimit    int
+	RateInterval time.Duration
+	// DedupWindow is how long an alert suppresses later alerts with the same key.
+	DedupWindow time.Duration
+	// QueueSize bounds the deliveries waiting to be retried. When it is exceeded the
+	// oldest are dropped.
+	QueueSize int
+	// MaxAttempts is the number of times a delivery is tried before it is dropped.
+	MaxAttempts int
+	// Backoff is the delay before the first retry; it doubles after each attempt up
+	// to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// delivery is an alert waiting to be delivered by one notifier.
+type delivery struct {
+	alert    Alert
+	notifier Notifier
+	attempts int
+	next     time.Time
+	delay    time.Duration
+}
+
+// Dispatcher sends alerts to notifiers, suppressing repeats and floods and retrying
+// failed deliveries. Repeats and the rate limit are judged by the alerts' own times,
+// so replaying an old log suppresses the same alerts as watching it live did.
+//
+// Its Dispatch method can be passed straight to RuleEngine.Run: a delivery that fails
+// for a reason that may pass is queued rather than reported, and tried again by a
+// later Dispatch or Retry once its backoff has passed, so an unreachable notifier
+// neither blocks nor stops the run. A Dispatcher is not safe for concurrent use.
+type Dispatcher struct {
+	Notifiers []Notifier
+
+	opts DispatchOptions
+	// now is the clock retries are scheduled by, and the time of alerts with none.
+	now func() time.Time
+	// seen holds when each alert key was last dispatched, and raised the times each
+	// rule raised an alert within the rate interval.
+	seen   map[string]time.Time
+	raised map[string][]time.Time
+	queue  []*delivery
+
+	suppressed, dropped int
+}
+
+// NewDispatcher returns a dispatcher sending to notifiers, with the given options, or
+// the defaults if opts is nil.
+func NewDispatcher(opts *DispatchOptions, notifiers ...Notifier) *Dispatcher {
+	o := DispatchOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.RateLimit <= 0 {
+		o.RateLimit = DefaultDispatchRateLimit
+	}
+	if o.RateInterval <= 0 {
+		o.RateInterval = DefaultDispatchRateInterval
+	}
+	if o.DedupWindow <= 0 {
+		o.DedupWindow = DefaultDispatchDedupWindow
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = DefaultDispatchQueueSize
+	}
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = DefaultDispatchMaxAttempts
+	}
+	if o.Backoff <= 0 {
+		o.Backoff = DefaultDispatchBackoff
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = DefaultDispatchMaxBackoff
+	}
+	return &Dispatcher{
+		Notifiers: notifiers,
+		opts:      o,
+		now:       time.Now,
+		seen:      make(map[string]time.Time),
+		raised:    make(map[string][]time.Time),
+	}
+}
+
+// alertKey returns the key identifying repeats of a.
+func alertKey(a Alert) string {
+	if a.Key != "" {
+		return a.Key
+	}
+	return a.Rule + ":" + a.Summary
+}
+
+// Dispatch sends a to every notifier, unless it repeats an alert within the dedup
+// window or its rule is over the rate limit. Deliveries that fail for a reason that
+// may pass are queued for retry. Dispatch returns an error only if a could not be
+// delivered and will not be retried; queued deliveries that are due are retried
+// first, but their errors are left to Retry.
+func (d *Dispatcher) Dispatch(a Alert) error {
+	d.Retry()
+	t := a.Time
+	if t.IsZero() {
+		t = d.now()
+	}
+	for key, seen := range d.seen {
+		if t.Sub(seen) >= d.opts.DedupWindow {
+			delete(d.seen, key)
+		}
+	}
+	for rule, times := range d.raised {
+		if len(times) == 0 || t.Sub(times[len(times)-1]) >= d.opts.RateInterval {
+			delete(d.raised, rule)
+		}
+	}
+	key := alertKey(a)
+	if _, ok := d.seen[key]; ok {
+		d.suppressed++
+		return nil
+	}
+	recent := d.raised[a.Rule]
+	for len(recent) > 0 && t.Sub(recent[0]) >= d.opts.RateInterval {
+		recent = recent[1:]
+	}
+	if len(recent) >= d.opts.RateLimit {
+		d.raised[a.Rule] = recent
+		d.suppressed++
+		return nil
+	}
+	d.raised[a.Rule] = append(recent, t)
+	d.seen[key] = t
+
+	now := d.now()
+	var first error
+	for _, n := range d.Notifiers {
+		q := &delivery{alert: a, notifier: n, delay: d.opts.Backoff}
+		retry, err := d.attempt(q, now)
+		switch {
+		case retry:
+			d.queue = append(d.queue, q)
+		case err != nil && first == nil:
+			first = err
+		}
+	}
+	if over := len(d.queue) - d.opts.QueueSize; over > 0 {
+		d.queue = d.queue[over:]
+		d.dropped += over
+	}
+	return first
+}
+
+// attempt tries to deliver q, reporting whether it should be retried and the error.
+func (d *Dispatcher) attempt(q *delivery, now time.Time) (retry bool, err error) {
+	err = q.notifier.Notify(q.alert)
+	if err == nil {
+		return false, nil
+	}
+	q.attempts++
+	if _, ok := err.(permanentError); ok || q.attempts >= d.opts.MaxAttempts {
+		d.dropped++
+		return false, err
+	}
+	q.next = now.Add(q.delay)
+	if q.delay *= 2; q.delay > d.opts.MaxBackoff {
+		q.delay = d.opts.MaxBackoff
+	}
+	return true, err
+}
+
+// Retry tries every queued delivery whose backoff has passed, and returns the first
+// error.
+func (d *Dispatcher) Retry() error {
+	now := d.now()
+	var first error
+	pending := d.queue[:0]
+	for _, q := range d.queue {
+		if q.next.After(now) {
+			pending = append(pending, q)
+			continue
+		}
+		retry, err := d.attempt(q, now)
+		if err != nil && first == nil {
+			first = err
+		}
+		if retry {
+			pending = append(pending, q)
+		}
+	}
+	for i := len(pending); i < len(d.queue); i++ {
+		d.queue[i] = nil
+	}
+	d.queue = pending
+	return first
+}
+
+// Pending returns the number of deliveries waiting to be retried.
+func (d *Dispatcher) Pending() int { return len(d.queue) }
+
+// Suppressed returns the number of alerts not sent because they were repeats or over
+// the rate limit.
+func (d *Dispatcher) Suppressed() int { return d.suppressed }
+
+// Dropped returns the number of deliveries abandoned because they failed for good,
+// ran out of attempts or overflowed the queue.
+func (d *Dispatcher) Dropped() int { return d.dropped }
+
+// alertJSON is the JSON layout of an Alert sent by WebhookNotifier.
+type alertJSON struct {
+	Rule     string        `json:"rule"`
+	Key      string        `json:"key"`
+	Time     string        `json:"time"`
+	Severity int           `json:"severity"`
+	Summary  string        `json:"summary"`
+	Source   string        `json:"source,omitempty"`
+	Logs     []*NetGearLog `json:"logs,omitempty"`
+}
+
+// defaultNotifyClient is used by notifiers without a Client. Its timeout keeps a
+// stalled server from holding up Dispatch.
+var defaultNotifyClient = &http.Client{Timeout: 30 * time.Second}
+
+// postJSON posts body to url. Network errors, rate limiting and server errors are
+// returned as they are, to be retried; other failures are permanent.
+func postJSON(client *http.Client, url string, body []byte, header http.Header) error {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{err}
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if client == nil {
+		client = defaultNotifyClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("webhook failed: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode/100 == 5 {
+		return err
+	}
+	return permanentError{err}
+}
+
+// WebhookSignatureHeader carries the HMAC-SHA256 of a webhook body, as "sha256=" and
+// the hex digest.
+const WebhookSignatureHeader = "X-Signature-256"
+
+// WebhookNotifier posts alerts as JSON objects to a URL.
+type WebhookNotifier struct {
+	URL string
+	// Secret, if set, signs each body with HMAC-SHA256 in WebhookSignatureHeader so
+	// the receiver can check it came from us.
+	Secret []byte
+	// Client is the HTTP client used. If nil, a client with a 30s timeout is used.
+	Client *http.Client
+}
+
+// WebhookSignature returns the WebhookSignatureHeader value for body signed with
+// secret.
+func WebhookSignature(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Notify posts a.
+func (n *WebhookNotifier) Notify(a Alert) error {
+	body, err := json.Marshal(alertJSON{
+		Rule:     a.Rule,
+		Key:      a.Key,
+		Time:     a.Time.Format(time.RFC3339),
+		Severity: a.Severity,
+		Summary:  a.Summary,
+		Source:   a.Source,
+		Logs:     a.Logs,
+	})
+	if err != nil {
+		return permanentError{err}
+	}
+	header := http.Header{}
+	if len(n.Secret) > 0 {
+		header.Set(WebhookSignatureHeader, WebhookSignature(n.Secret, body))
+	}
+	return postJSON(n.Client, n.URL, body, header)
+}
+
+// SlackNotifier posts alerts to a Slack incoming webhook, or any service accepting
+// the same payload.
+type SlackNotifier struct {
+	URL string
+	// Channel and Username, if set, override the webhook's defaults.
+	Channel  string
+	Username string
+	// Client is the HTTP client used. If nil, a client with a 30s timeout is used.
+	Client *http.Client
+}
+
+type slackPayload struct {
+	Text     string `json:"text"`
+	Channel  string `json:"channel,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+// alertSubject returns a one-line description of a.
+func alertSubject(a Alert) string {
+	s := fmt.Sprintf("[%s] %s", a.Rule, a.Summary)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Notify posts a.
+func (n *SlackNotifier) Notify(a Alert) error {
+	text := fmt.Sprintf("*%s* (severity %d) at %s\n%s", a.Rule, a.Severity, a.Time.Format(reportTimeFmt), a.Summary)
+	body, err := json.Marshal(slackPayload{Text: text, Channel: n.Channel, Username: n.Username})
+	if err != nil {
+		return permanentError{err}
+	}
+	return postJSON(n.Client, n.URL, body, nil)
+}
+
+// SMTPNotifier emails alerts. It upgrades the connection with STARTTLS when the server
+// offers it, and authenticates with PLAIN if a username is set; net/smtp only sends
+// the password over TLS or to localhost.
+type SMTPNotifier struct {
+	// Addr is the server's host:port.
+	Addr     string
+	From     string
+	To       []string
+	Username string
+	Password string
+	// RequireTLS refuses to send if the server does not offer STARTTLS.
+	RequireTLS bool
+	// TLSConfig is used for STARTTLS. Its ServerName defaults to the host of Addr.
+	TLSConfig *tls.Config
+	// Hostname is sent in EHLO. Defaults to localhost.
+	Hostname string
+	// Timeout bounds the whole exchange with the server, from connecting to sending
+	// the message. Defaults to 30s.
+	Timeout time.Duration
+}
+
+// Notify emails a.
+func (n *SMTPNotifier) Notify(a Alert) error {
+	msg := n.message(a)
+	err := n.send(msg)
+	// 5xx replies, such as a rejected login or recipient, will not succeed on retry.
+	if te, ok := err.(*textproto.Error); ok && te.Code/100 == 5 {
+		return permanentError{err}
+	}
+	return err
+}
+
+func (n *SMTPNotifier) message(a Alert) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", n.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(n.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", alertSubject(a))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&b, "%s\r\n\r\nRule: %s\r\nSeverity: %d\r\nTime: %s\r\n", a.Summary, a.Rule, a.Severity, a.Time.Format(reportTimeFmt))
+	if a.Source != "" {
+		fmt.Fprintf(&b, "Source: %s\r\n", a.Source)
+	}
+	if len(a.Logs) > 0 {
+		b.WriteString("\r\n")
+		for _, l := range a.Logs {
+			line := l.Raw
+			if line == "" {
+				line = l.String()
+			}
+			fmt.Fprintf(&b, "%s\r\n", line)
+		}
+	}
+	return b.Bytes()
+}
+
+func (n *SMTPNotifier) send(msg []byte) error {
+	host, _, err := net.SplitHostPort(n.Addr)
+	if err != nil {
+		return permanentError{err}
+	}
+	timeout := n.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	conn, err := net.DialTimeout("tcp", n.Addr, timeout)
+	if err != nil {
+		return err
+	}
+	// The deadline also covers the TLS connection StartTLS layers on top.
+	conn.SetDeadline(time.Now().Add(timeout))
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	hostname := n.Hostname
+	if hostname == "" {
+		hostname = "localhost"
+	}
+	if err := c.Hello(hostname); err != nil {
+		return err
+	}
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		cfg := &tls.Config{}
+		if n.TLSConfig != nil {
+			cfg = n.TLSConfig.Clone()
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName = host
+		}
+		if err := c.StartTLS(cfg); err != nil {
+			return err
+		}
+	} else if n.RequireTLS {
+		return fmt.Errorf("smtp: %s does not offer STARTTLS", n.Addr)
+	}
+	if n.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", n.Username, n.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(n.From); err != nil {
+		return err
+	}
+	for _, to := range n.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,330 @@
+package netgearlogs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeNotifier struct {
+	fail int
+	// permanent makes the failures permanent.
+	permanent bool
+	sent      []Alert
+}
+
+func (n *fakeNotifier) Notify(a Alert) error {
+	if n.fail > 0 {
+		n.fail--
+		if n.permanent {
+			return permanentError{errors.New("rejected")}
+		}
+		return errors.New("unavailable")
+	}
+	n.sent = append(n.sent, a)
+	return nil
+}
+
+func TestDispatcher(t *testing.T) {
+	// The clock never moves: repeats and the rate limit go by the alerts' times.
+	n := &fakeNotifier{}
+	d := NewDispatcher(&DispatchOptions{RateLimit: 2, RateInterval: time.Hour, DedupWindow: 10 * time.Minute}, n)
+	d.now = func() time.Time { return time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC) }
+	base := time.Date(2016, 2, 22, 12, 0, 0, 0, time.UTC)
+	alert := func(key string, min int) Alert {
+		return Alert{Rule: "admin-login", Key: "admin-login:" + key, Time: base.Add(time.Duration(min) * time.Minute)}
+	}
+
+	for _, a := range []Alert{alert("a", 0), alert("a", 1), alert("b", 2), alert("c", 3)} {
+		if err := d.Dispatch(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(n.sent) != 2 || d.Suppressed() != 2 {
+		t.Fatalf("Expected a repeat and a rate limited alert to be suppressed, sent %+v", n.sent)
+	}
+	// After the dedup window a repeat is sent again, but the rule is still limited.
+	d.Dispatch(alert("a", 15))
+	if len(n.sent) != 2 {
+		t.Errorf("Expected the rate limit to hold, sent %d", len(n.sent))
+	}
+	d.Dispatch(alert("a", 75))
+	if len(n.sent) != 3 {
+		t.Errorf("Expected the alert to be sent once the rate limit passed, sent %d", len(n.sent))
+	}
+
+	// State for a rule that stops firing is dropped once it has aged out.
+	d.Dispatch(Alert{Rule: "wlan-brute-force", Key: "wlan-brute-force:x", Time: base.Add(200 * time.Minute)})
+	if _, ok := d.raised["admin-login"]; ok || len(d.raised) != 1 || len(d.seen) != 1 {
+		t.Errorf("Expected stale rules and keys to be pruned, got %d rules and %d keys", len(d.raised), len(d.seen))
+	}
+}
+
+func TestDispatcherRetry(t *testing.T) {
+	now := time.Date(2016, 2, 22, 12, 0, 0, 0, time.UTC)
+	n := &fakeNotifier{fail: 2}
+	d := NewDispatcher(&DispatchOptions{Backoff: time.Minute, MaxAttempts: 3}, n)
+	d.now = func() time.Time { return now }
+	if err := d.Dispatch(Alert{Rule: "wlan-brute-force", Key: "x"}); err != nil {
+		t.Fatalf("Expected a failure to be queued, not returned: %v", err)
+	}
+	if d.Pending() != 1 {
+		t.Fatalf("Expected the delivery to be queued, got %d", d.Pending())
+	}
+	now = now.Add(30 * time.Second)
+	if err := d.Retry(); err != nil || d.Pending() != 1 {
+		t.Fatalf("Expected no retry before the backoff passed, got %v", err)
+	}
+	now = now.Add(30 * time.Second)
+	if err := d.Retry(); err == nil {
+		t.Fatal("Expected the second attempt to fail")
+	}
+	now = now.Add(2 * time.Minute)
+	if err := d.Retry(); err != nil || d.Pending() != 0 || len(n.sent) != 1 {
+		t.Fatalf("Expected the third attempt to deliver, got %v with %d pending", err, d.Pending())
+	}
+
+	n.fail = 5
+	d.Dispatch(Alert{Rule: "wlan-brute-force", Key: "y"})
+	for i := 0; i < 3; i++ {
+		now = now.Add(time.Hour)
+		d.Retry()
+	}
+	if d.Pending() != 0 || d.Dropped() != 1 {
+		t.Errorf("Expected the delivery to be dropped after 3 attempts, %d pending, %d dropped", d.Pending(), d.Dropped())
+	}
+
+	n.fail, n.permanent = 1, true
+	if err := d.Dispatch(Alert{Rule: "wlan-brute-force", Key: "z"}); err == nil || d.Pending() != 0 {
+		t.Errorf("Expected a permanent failure to be returned and not queued, got %v", err)
+	}
+}
+
+func TestDispatcherRuleEngineRun(t *testing.T) {
+	logs := parseLines([]string{
+		"[admin login] from source 8.8.8.8, Tuesday, February 23, 2016 19:06:07",
+		"[admin login] from source 8.8.4.4, Tuesday, February 23, 2016 19:07:07",
+		"[admin login] from source 1.1.1.1, Tuesday, February 23, 2016 19:08:07",
+	})
+	n := &fakeNotifier{fail: 100}
+	d := NewDispatcher(nil, n)
+	if err := NewRuleEngine().Run(NewSliceSource(logs), d.Dispatch); err != nil {
+		t.Fatalf("Expected failing deliveries not to stop the run, got %v", err)
+	}
+	if d.Pending() != 3 {
+		t.Errorf("Expected 3 deliveries queued for retry, got %d", d.Pending())
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	secret := []byte("s3cret")
+	var got alertJSON
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if sig := r.Header.Get(WebhookSignatureHeader); sig != WebhookSignature(secret, body) {
+			t.Errorf("Bad signature %q", sig)
+		}
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	logs := parseLines([]string{"[admin login] from source 192.168.1.6, Tuesday, February 23, 2016 19:06:07"})
+	a := NewAdminLoginRule().Check(logs[0])
+	n := &WebhookNotifier{URL: srv.URL, Secret: secret}
+	if err := n.Notify(*a); err != nil {
+		t.Fatal(err)
+	}
+	if got.Rule != "admin-login" || got.Key != "admin-login:192.168.1.6" || got.Time != "2016-02-23T19:06:07Z" || len(got.Logs) != 1 || got.Logs[0].Raw != logs[0].Raw {
+		t.Errorf("Unexpected payload %+v", got)
+	}
+
+	status = http.StatusServiceUnavailable
+	if _, ok := n.Notify(*a).(permanentError); ok {
+		t.Error("Expected a server error to be retried")
+	}
+	status = http.StatusBadRequest
+	if _, ok := n.Notify(*a).(permanentError); !ok {
+		t.Error("Expected a client error to be permanent")
+	}
+}
+
+func TestWebhookNotifierStalled(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+	saved := defaultNotifyClient
+	defaultNotifyClient = &http.Client{Timeout: 100 * time.Millisecond}
+	defer func() { defaultNotifyClient = saved }()
+
+	err := (&WebhookNotifier{URL: srv.URL}).Notify(Alert{Rule: "admin-login"})
+	if err == nil {
+		t.Fatal("Expected a stalled server to time out")
+	}
+	if _, ok := err.(permanentError); ok {
+		t.Error("Expected a timeout to be retried")
+	}
+}
+
+func TestSlackNotifier(t *testing.T) {
+	var got slackPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	n := &SlackNotifier{URL: srv.URL, Channel: "#alerts"}
+	a := Alert{Rule: "wlan-brute-force", Severity: 7, Time: time.Date(2016, 2, 22, 16, 0, 0, 0, time.UTC), Summary: "5 WLAN rejections"}
+	if err := n.Notify(a); err != nil {
+		t.Fatal(err)
+	}
+	if got.Channel != "#alerts" || got.Text != "*wlan-brute-force* (severity 7) at 2016-02-22 16:00:00\n5 WLAN rejections" {
+		t.Errorf("Unexpected payload %+v", got)
+	}
+}
+
+// fakeSMTP serves one SMTP session on l, offering STARTTLS with cfg and AUTH PLAIN,
+// and sends the message received, or an error, on msgs.
+func fakeSMTP(l net.Listener, cfg *tls.Config, msgs chan<- string) {
+	conn, err := l.Accept()
+	if err != nil {
+		msgs <- err.Error()
+		return
+	}
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 fake ESMTP")
+	secure, authed := false, false
+	var msg string
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			msgs <- err.Error()
+			return
+		}
+		cmd := strings.ToUpper(strings.Fields(line)[0])
+		switch cmd {
+		case "EHLO":
+			if secure {
+				tp.PrintfLine("250-fake\r\n250 AUTH PLAIN")
+			} else {
+				tp.PrintfLine("250-fake\r\n250 STARTTLS")
+			}
+		case "STARTTLS":
+			tp.PrintfLine("220 go ahead")
+			tc := tls.Server(conn, cfg)
+			if err := tc.Handshake(); err != nil {
+				msgs <- err.Error()
+				return
+			}
+			conn, tp, secure = tc, textproto.NewConn(tc), true
+		case "AUTH":
+			cred, _ := base64.StdEncoding.DecodeString(strings.Fields(line)[2])
+			if string(cred) != "\x00router\x00hunter2" {
+				tp.PrintfLine("535 bad credentials")
+				continue
+			}
+			authed = true
+			tp.PrintfLine("235 ok")
+		case "MAIL", "RCPT":
+			if !authed {
+				tp.PrintfLine("530 authentication required")
+				continue
+			}
+			tp.PrintfLine("250 ok")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			b, _ := tp.ReadDotBytes()
+			msg = string(b)
+			tp.PrintfLine("250 queued")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			msgs <- msg
+			return
+		default:
+			tp.PrintfLine("502 unknown")
+		}
+	}
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	// The httptest TLS server supplies a certificate for 127.0.0.1.
+	ts := httptest.NewTLSServer(http.NotFoundHandler())
+	defer ts.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(ts.Certificate())
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	msgs := make(chan string, 1)
+	go fakeSMTP(l, ts.TLS, msgs)
+
+	n := &SMTPNotifier{
+		Addr:       l.Addr().String(),
+		From:       "router@example.com",
+		To:         []string{"admin@example.com"},
+		Username:   "router",
+		Password:   "hunter2",
+		RequireTLS: true,
+		TLSConfig:  &tls.Config{RootCAs: roots},
+	}
+	a := Alert{Rule: "admin-login", Severity: 9, Time: time.Date(2016, 2, 23, 19, 6, 7, 0, time.UTC), Summary: "admin login from 8.8.8.8,\r\nBcc: x@example.com", Source: "8.8.8.8"}
+	if err := n.Notify(a); err != nil {
+		t.Fatal(err)
+	}
+	msg := <-msgs
+	if !strings.Contains(msg, "Subject: [admin-login] admin login from 8.8.8.8, Bcc: x@example.com\n") {
+		t.Errorf("Expected a single-line subject in\n%s", msg)
+	}
+	if !strings.Contains(msg, "Source: 8.8.8.8\n") || !strings.Contains(msg, "To: admin@example.com\n") {
+		t.Errorf("Unexpected message\n%s", msg)
+	}
+}
+
+func TestSMTPNotifierStalled(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	// The server accepts the connection and never greets.
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(io.Discard, conn)
+	}()
+
+	n := &SMTPNotifier{Addr: l.Addr().String(), From: "router@example.com", To: []string{"admin@example.com"}, Timeout: 100 * time.Millisecond}
+	done := make(chan error, 1)
+	go func() { done <- n.Notify(Alert{Rule: "admin-login"}) }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Expected a stalled server to time out")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Notify to give up on a stalled server")
+	}
+}
@@ -0,0 +1,163 @@
+package netgearlogs
+
+import (
+	"container/heap"
+	"io"
+	"sort"
+)
+
+// SortLogs sorts logs into ascending time order. Entries with the same timestamp keep
+// their original relative position, and nil entries (as left by ParseNetGearLog for
+// unparseable lines) are moved to the end.
+//
+// Routers export their logs newest first, so entries within the same second appear
+// in reverse order of occurrence. Call ReverseLogs before SortLogs on such an export
+// to keep same-second entries in the order they happened.
+func SortLogs(logs []*NetGearLog) {
+	sort.SliceStable(logs, func(i, j int) bool {
+		return logBefore(logs[i], logs[j])
+	})
+}
+
+// ReverseLogs reverses logs in place.
+func ReverseLogs(logs []*NetGearLog) {
+	for i, j := 0, len(logs)-1; i < j; i, j = i+1, j-1 {
+		logs[i], logs[j] = logs[j], logs[i]
+	}
+}
+
+// LogsSorted reports whether logs are in ascending time order.
+func LogsSorted(logs []*NetGearLog) bool {
+	return sort.SliceIsSorted(logs, func(i, j int) bool {
+		return logBefore(logs[i], logs[j])
+	})
+}
+
+// newestFirst reports whether logs are in descending time order, as the router
+// exports them. Entries all with the same time count as descending. Nil entries are
+// ignored.
+func newestFirst(logs []*NetGearLog) bool {
+	var prev *NetGearLog
+	for _, l := range logs {
+		if l == nil {
+			continue
+		}
+		if prev != nil && l.Time.After(prev.Time) {
+			return false
+		}
+		prev = l
+	}
+	return true
+}
+
+// sortOccurrences sorts logs into ascending time order with entries from the same
+// second in the order they happened. A newest-first export is reversed before the
+// sort, since its same-second entries are in reverse order; entries all from the same
+// second are taken to be such an export. Any other order is sorted as it is.
+func sortOccurrences(logs []*NetGearLog) {
+	if newestFirst(logs) {
+		ReverseLogs(logs)
+	}
+	SortLogs(logs)
+}
+
+// MergeLogs merges slices that are each already in ascending time order into a single
+// ascending slice. Entries with equal timestamps are taken from earlier slices first.
+func MergeLogs(sorted ...[]*NetGearLog) []*NetGearLog {
+	n := 0
+	srcs := make([]LogSource, len(sorted))
+	for i, logs := range sorted {
+		n += len(logs)
+		srcs[i] = NewSliceSource(logs)
+	}
+	merged := make([]*NetGearLog, 0, n)
+	m := NewMergeSource(srcs...)
+	for {
+		log, err := m.Next()
+		if err != nil {
+			break
+		}
+		merged = append(merged, log)
+	}
+	return merged
+}
+
+// NewMergeSource returns a LogSource that interleaves several sources, each already in
+// ascending time order, into one ascending stream. Only one pending entry per source
+// is held in memory at a time. Entries with equal timestamps are taken from earlier
+// sources first.
+//
+// Errors from the underlying sources are passed through. A source that returns a
+// *ParseError stays in the merge; one that returns any other error is dropped.
+func NewMergeSource(sources ...LogSource) LogSource {
+	m := &mergeSource{srcs: sources}
+	for i := len(sources) - 1; i >= 0; i-- {
+		m.fill = append(m.fill, i)
+	}
+	return m
+}
+
+type mergeSource struct {
+	srcs []LogSource
+	h    mergeHeap
+	fill []int // sources that have no entry on the heap
+}
+
+func (m *mergeSource) Next() (*NetGearLog, error) {
+	for len(m.fill) > 0 {
+		i := m.fill[len(m.fill)-1]
+		log, err := m.srcs[i].Next()
+		if err != nil {
+			if _, ok := err.(*ParseError); ok {
+				return nil, err
+			}
+			m.fill = m.fill[:len(m.fill)-1]
+			if err == io.EOF {
+				continue
+			}
+			return nil, err
+		}
+		m.fill = m.fill[:len(m.fill)-1]
+		heap.Push(&m.h, mergeItem{log: log, src: i})
+	}
+	if m.h.Len() == 0 {
+		return nil, io.EOF
+	}
+	it := heap.Pop(&m.h).(mergeItem)
+	m.fill = append(m.fill, it.src)
+	return it.log, nil
+}
+
+type mergeItem struct {
+	log *NetGearLog
+	src int
+}
+
+type mergeHeap []mergeItem
+
+func (h mergeHeap) Len() int { return len(h) }
+func (h mergeHeap) Less(i, j int) bool {
+	if logBefore(h[i].log, h[j].log) {
+		return true
+	}
+	if logBefore(h[j].log, h[i].log) {
+		return false
+	}
+	return h[i].src < h[j].src
+}
+func (h mergeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x interface{}) { *h = append(*h, x.(mergeItem)) }
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	it := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return it
+}
+
+// logBefore orders entries by time, placing nil entries last.
+func logBefore(a, b *NetGearLog) bool {
+	if a == nil || b == nil {
+		return a != nil
+	}
+	return a.Time.Before(b.Time)
+}
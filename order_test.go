@@ -0,0 +1,154 @@
+package netgearlogs
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func logAt(event, source, ts string) *NetGearLog {
+	tm, _ := time.Parse(netgearLogDateFmt, ts)
+	return &NetGearLog{EventType: event, FromSource: source, Time: tm}
+}
+
+func TestSortLogFile(t *testing.T) {
+	f, err := os.Open("log.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	logs, _ := ParseNetGearLog(f)
+	if LogsSorted(logs) {
+		t.Fatal("Expected log.txt to be in reverse order")
+	}
+	ReverseLogs(logs)
+	SortLogs(logs)
+	if !LogsSorted(logs) {
+		t.Error("Expected logs to be sorted")
+	}
+	for i := 1; i < len(logs) && logs[i] != nil; i++ {
+		if logs[i].Time.Before(logs[i-1].Time) {
+			t.Fatalf("Entry %d (%s) is before entry %d (%s)", i, logs[i].Time, i-1, logs[i-1].Time)
+		}
+	}
+}
+
+func TestSortLogsStable(t *testing.T) {
+	ts := "Monday, February 22, 2016 18:31:22"
+	logs := []*NetGearLog{
+		logAt(eventDHCPIP, "192.168.1.6", ts),
+		nil,
+		logAt(eventDHCPIP, "192.168.1.7", ts),
+		logAt(eventAdminLogin, "192.168.1.6", "Monday, February 22, 2016 18:31:21"),
+		logAt(eventDHCPIP, "192.168.1.8", ts),
+	}
+	SortLogs(logs)
+	expected := []string{"192.168.1.6", "192.168.1.6", "192.168.1.7", "192.168.1.8"}
+	for i, src := range expected {
+		if logs[i].FromSource != src {
+			t.Errorf("%d: expected %s, got %s", i, src, logs[i].FromSource)
+		}
+	}
+	if logs[0].EventType != eventAdminLogin {
+		t.Errorf("Expected the earlier admin login first, got %s", logs[0].EventType)
+	}
+	if logs[4] != nil {
+		t.Errorf("Expected nil entry last, got %+v", logs[4])
+	}
+}
+
+func TestSortOccurrences(t *testing.T) {
+	ts := "Monday, February 22, 2016 18:31:22"
+	sources := func(logs []*NetGearLog) string {
+		var s []string
+		for _, l := range logs {
+			s = append(s, l.FromSource)
+		}
+		return strings.Join(s, " ")
+	}
+	// Newest first: the same-second entries are reversed back into occurrence order.
+	logs := []*NetGearLog{
+		logAt(eventDHCPIP, "3", "Monday, February 22, 2016 18:31:23"),
+		logAt(eventDHCPIP, "2", ts),
+		nil,
+		logAt(eventDHCPIP, "1", ts),
+	}
+	sortOccurrences(logs)
+	if got := sources(logs[:3]); got != "1 2 3" || logs[3] != nil {
+		t.Errorf("Unexpected newest-first order %s", got)
+	}
+	// Unordered input is sorted without reversing ties.
+	logs = []*NetGearLog{
+		logAt(eventDHCPIP, "1", ts),
+		logAt(eventDHCPIP, "3", "Monday, February 22, 2016 18:31:23"),
+		logAt(eventDHCPIP, "2", ts),
+		logAt(eventDHCPIP, "0", "Monday, February 22, 2016 18:31:21"),
+	}
+	sortOccurrences(logs)
+	if got := sources(logs); got != "0 1 2 3" {
+		t.Errorf("Unexpected order of unordered input %s", got)
+	}
+}
+
+func TestMergeLogs(t *testing.T) {
+	a := []*NetGearLog{
+		logAt(eventDoSAttackRstScan, "a", "Monday, February 22, 2016 10:00:00"),
+		logAt(eventDoSAttackRstScan, "a", "Monday, February 22, 2016 12:00:00"),
+		logAt(eventDoSAttackRstScan, "a", "Monday, February 22, 2016 14:00:00"),
+	}
+	b := []*NetGearLog{
+		logAt(eventDoSAttackAckScan, "b", "Monday, February 22, 2016 11:00:00"),
+		logAt(eventDoSAttackAckScan, "b", "Monday, February 22, 2016 12:00:00"),
+	}
+	c := []*NetGearLog{}
+	merged := MergeLogs(a, b, c)
+	expected := []string{"a", "b", "a", "b", "a"}
+	if len(merged) != len(expected) {
+		t.Fatalf("Expected %d entries, got %d", len(expected), len(merged))
+	}
+	for i, src := range expected {
+		if merged[i].FromSource != src {
+			t.Errorf("%d: expected %s, got %s", i, src, merged[i].FromSource)
+		}
+	}
+}
+
+type errorSource struct {
+	logs []*NetGearLog
+	errs []error
+}
+
+func (s *errorSource) Next() (*NetGearLog, error) {
+	if len(s.errs) == 0 {
+		return nil, io.EOF
+	}
+	log, err := s.logs[0], s.errs[0]
+	s.logs, s.errs = s.logs[1:], s.errs[1:]
+	return log, err
+}
+
+func TestMergeSourcePassesParseErrors(t *testing.T) {
+	bad := &errorSource{
+		logs: []*NetGearLog{nil, logAt(eventAdminLogin, "bad", "Monday, February 22, 2016 11:00:00")},
+		errs: []error{&ParseError{Line: 1, Text: "garbage"}, nil},
+	}
+	good := NewSliceSource([]*NetGearLog{logAt(eventAdminLogin, "good", "Monday, February 22, 2016 10:00:00")})
+	m := NewMergeSource(bad, good)
+	if _, err := m.Next(); err == nil {
+		t.Fatal("Expected parse error to be passed through")
+	}
+	for _, src := range []string{"good", "bad"} {
+		l, err := m.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l.FromSource != src {
+			t.Errorf("Expected %s, got %s", src, l.FromSource)
+		}
+	}
+	if _, err := m.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+}
@@ -0,0 +1,233 @@
+package netgearlogs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// otlpScopeName identifies this package as the instrumentation scope of exported records.
+const otlpScopeName = "github.com/klauern/go-netgearlogs"
+
+// OTLP severity numbers, from the OpenTelemetry logs data model.
+const (
+	otlpSeverityInfo  = 9
+	otlpSeverityInfo2 = 10
+	otlpSeverityWarn  = 13
+	otlpSeverityError = 17
+)
+
+// OTLPAnyValue is the JSON form of an OTLP AnyValue holding a string or an integer.
+type OTLPAnyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+// OTLPKeyValue is the JSON form of an OTLP attribute.
+type OTLPKeyValue struct {
+	Key   string       `json:"key"`
+	Value OTLPAnyValue `json:"value"`
+}
+
+// OTLPLogRecord is the JSON form of an OTLP LogRecord.
+type OTLPLogRecord struct {
+	TimeUnixNano   string         `json:"timeUnixNano"`
+	SeverityNumber int            `json:"severityNumber"`
+	SeverityText   string         `json:"severityText"`
+	Body           OTLPAnyValue   `json:"body"`
+	Attributes     []OTLPKeyValue `json:"attributes,omitempty"`
+}
+
+func otlpString(key, value string) OTLPKeyValue {
+	return OTLPKeyValue{Key: key, Value: OTLPAnyValue{StringValue: &value}}
+}
+
+func otlpInt(key string, value int) OTLPKeyValue {
+	s := strconv.Itoa(value)
+	return OTLPKeyValue{Key: key, Value: OTLPAnyValue{IntValue: &s}}
+}
+
+// otlpSeverity maps the entry's 0-10 SIEM severity onto the OTLP severity numbers.
+func otlpSeverity(l *NetGearLog) (int, string) {
+	switch sev := siemEventFor(l).severity; {
+	case sev >= 7:
+		return otlpSeverityError, "ERROR"
+	case sev >= 5:
+		return otlpSeverityWarn, "WARN"
+	case sev >= 3:
+		return otlpSeverityInfo2, "INFO2"
+	}
+	return otlpSeverityInfo, "INFO"
+}
+
+// ToOTLP maps the entry onto the OTLP LogRecord model. The body is the raw log line,
+// and network fields are attributes named after the OpenTelemetry semantic
+// conventions.
+func (l *NetGearLog) ToOTLP() OTLPLogRecord {
+	num, text := otlpSeverity(l)
+	body := l.Raw
+	if body == "" {
+		body = l.String()
+	}
+	r := OTLPLogRecord{
+		TimeUnixNano:   strconv.FormatInt(l.Time.UnixNano(), 10),
+		SeverityNumber: num,
+		SeverityText:   text,
+		Body:           OTLPAnyValue{StringValue: &body},
+	}
+	attrs := []OTLPKeyValue{
+		otlpString("event.name", "netgear."+strings.Replace(ecsAction(l.Kind()), "-", "_", -1)),
+		otlpString("netgear.event_type", l.EventType),
+	}
+	src, spt, dst, dpt, mac := siemAddresses(l)
+	if src != "" {
+		attrs = append(attrs, otlpString("source.address", src))
+	}
+	if spt != 0 {
+		attrs = append(attrs, otlpInt("source.port", spt))
+	}
+	if dst == "" {
+		dst = siemDestHost(l)
+	}
+	if dst != "" {
+		attrs = append(attrs, otlpString("destination.address", dst))
+	}
+	if dpt != 0 {
+		attrs = append(attrs, otlpInt("destination.port", dpt))
+	}
+	if mac != "" {
+		attrs = append(attrs, otlpString("netgear.mac_address", mac))
+	}
+	if l.Device != "" {
+		attrs = append(attrs, otlpString("netgear.device", l.Device))
+	}
+	if l.File != "" {
+		attrs = append(attrs, otlpString("log.file.path", l.File))
+	}
+	r.Attributes = attrs
+	return r
+}
+
+type otlpResourceLogs struct {
+	Resource struct {
+		Attributes []OTLPKeyValue `json:"attributes"`
+	} `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpScopeLogs struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	LogRecords []OTLPLogRecord `json:"logRecords"`
+}
+
+type otlpExportRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+// OTLPExporter sends entries to an OpenTelemetry collector using OTLP/HTTP with JSON
+// encoding. The router is described by resource attributes. Entries are batched and
+// retried according to BatchOptions.
+type OTLPExporter struct {
+	// Endpoint is the full URL of the logs endpoint, e.g. http://localhost:4318/v1/logs.
+	Endpoint string
+	// Router and Model describe the router as service.instance.id and device.model.identifier.
+	Router string
+	Model  string
+	// Headers are added to every request, e.g. for authentication.
+	Headers map[string]string
+	// Client is the HTTP client used; http.DefaultClient if nil.
+	Client *http.Client
+
+	b *batcher
+}
+
+// NewOTLPExporter returns an exporter sending to the logs endpoint at url, with
+// entries attributed to the named router.
+func NewOTLPExporter(url, router string, opts BatchOptions) *OTLPExporter {
+	e := &OTLPExporter{Endpoint: url, Router: router, Model: "WNDR4300"}
+	e.b = newBatcher(opts, e.send)
+	return e
+}
+
+// Export queues l. Full batches are sent in the background, so Export does not wait
+// on the destination.
+func (e *OTLPExporter) Export(l *NetGearLog) error { return e.b.add(l) }
+
+// Flush sends every queued entry.
+func (e *OTLPExporter) Flush() error { return e.b.flush() }
+
+// Dropped returns the number of entries discarded because the buffer overflowed or
+// the collector rejected them.
+func (e *OTLPExporter) Dropped() int { return e.b.droppedCount() }
+
+// request builds the ExportLogsServiceRequest for a batch.
+func (e *OTLPExporter) request(batch []*NetGearLog) otlpExportRequest {
+	rl := otlpResourceLogs{}
+	rl.Resource.Attributes = []OTLPKeyValue{
+		otlpString("service.name", "netgear-router"),
+		otlpString("device.manufacturer", "NETGEAR"),
+	}
+	if e.Model != "" {
+		rl.Resource.Attributes = append(rl.Resource.Attributes, otlpString("device.model.identifier", e.Model))
+	}
+	if e.Router != "" {
+		rl.Resource.Attributes = append(rl.Resource.Attributes, otlpString("service.instance.id", e.Router))
+	}
+	sl := otlpScopeLogs{}
+	sl.Scope.Name = otlpScopeName
+	for _, l := range batch {
+		sl.LogRecords = append(sl.LogRecords, l.ToOTLP())
+	}
+	rl.ScopeLogs = []otlpScopeLogs{sl}
+	return otlpExportRequest{ResourceLogs: []otlpResourceLogs{rl}}
+}
+
+func (e *OTLPExporter) send(batch []*NetGearLog) error {
+	body, err := json.Marshal(e.request(batch))
+	if err != nil {
+		return permanentError{err}
+	}
+	req, err := http.NewRequest("POST", e.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var result struct {
+			PartialSuccess struct {
+				RejectedLogRecords json.Number `json:"rejectedLogRecords"`
+				ErrorMessage       string      `json:"errorMessage"`
+			} `json:"partialSuccess"`
+		}
+		if json.Unmarshal(msg, &result) == nil {
+			if n, _ := result.PartialSuccess.RejectedLogRecords.Int64(); n > 0 {
+				return partialError{int(n), fmt.Errorf("collector rejected %d log records: %s", n, result.PartialSuccess.ErrorMessage)}
+			}
+		}
+		return nil
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		// The OTLP specification lists these as retryable.
+		return fmt.Errorf("otlp export failed: %s", resp.Status)
+	}
+	return permanentError{fmt.Errorf("otlp export failed: %s: %s", resp.Status, bytes.TrimSpace(msg))}
+}
@@ -0,0 +1,127 @@
+package netgearlogs
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func otlpAttrs(r OTLPLogRecord) map[string]string {
+	m := make(map[string]string)
+	for _, kv := range r.Attributes {
+		if kv.Value.StringValue != nil {
+			m[kv.Key] = *kv.Value.StringValue
+		} else if kv.Value.IntValue != nil {
+			m[kv.Key] = *kv.Value.IntValue
+		}
+	}
+	return m
+}
+
+func TestToOTLP(t *testing.T) {
+	l, err := ParseNetGearLogLine("[LAN access from remote] from 80.82.79.104:46589 to 192.168.1.9:8080, Monday, February 22, 2016 13:11:37")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := l.ToOTLP()
+	if r.TimeUnixNano != "1456146697000000000" {
+		t.Errorf("Unexpected time %s", r.TimeUnixNano)
+	}
+	if r.SeverityNumber != otlpSeverityError || r.SeverityText != "ERROR" {
+		t.Errorf("Unexpected severity %d %s", r.SeverityNumber, r.SeverityText)
+	}
+	if *r.Body.StringValue != l.Raw {
+		t.Errorf("Expected raw line as body, got %s", *r.Body.StringValue)
+	}
+	expected := map[string]string{
+		"event.name":          "netgear.lan_access_from_remote",
+		"source.address":      "80.82.79.104",
+		"source.port":         "46589",
+		"destination.address": "192.168.1.9",
+		"destination.port":    "8080",
+	}
+	attrs := otlpAttrs(r)
+	for k, v := range expected {
+		if attrs[k] != v {
+			t.Errorf("%s: expected %s, got %s", k, v, attrs[k])
+		}
+	}
+
+	dhcp, err := ParseNetGearLogLine("[DHCP IP: 192.168.1.6] to MAC address b4:b6:76:bf:19:8b, Monday, February 22, 2016 18:31:22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = dhcp.ToOTLP()
+	if attrs := otlpAttrs(r); attrs["destination.address"] != "192.168.1.6" || attrs["netgear.mac_address"] != "b4:b6:76:bf:19:8b" {
+		t.Errorf("Unexpected DHCP attributes %v", attrs)
+	}
+	if r.SeverityNumber != otlpSeverityInfo {
+		t.Errorf("Expected INFO for DHCP, got %d", r.SeverityNumber)
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var requests []otlpExportRequest
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path != "/v1/logs" || r.Header.Get("Content-Type") != "application/json" || r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("Unexpected request to %s", r.URL.Path)
+		}
+		var req otlpExportRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		requests = append(requests, req)
+		io.WriteString(w, `{}`)
+	}))
+	defer srv.Close()
+
+	e := NewOTLPExporter(srv.URL+"/v1/logs", "upstairs", BatchOptions{})
+	e.Headers = map[string]string{"Authorization": "Bearer token"}
+	e.b.sleep = func(time.Duration) {}
+	for _, l := range parseLines(readLogLines(t)[2:12]) {
+		e.Export(l)
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 2 || len(requests) != 1 {
+		t.Fatalf("Expected a retry then one request, got %d attempts", attempts)
+	}
+	rl := requests[0].ResourceLogs[0]
+	res := otlpAttrs(OTLPLogRecord{Attributes: rl.Resource.Attributes})
+	if res["service.instance.id"] != "upstairs" || res["device.manufacturer"] != "NETGEAR" {
+		t.Errorf("Unexpected resource %v", res)
+	}
+	if rl.ScopeLogs[0].Scope.Name != otlpScopeName || len(rl.ScopeLogs[0].LogRecords) != 10 {
+		t.Errorf("Expected 10 records in scope %s", otlpScopeName)
+	}
+}
+
+func TestOTLPExporterPartialSuccess(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"partialSuccess":{"rejectedLogRecords":"1","errorMessage":"too old"}}`)
+	}))
+	defer srv.Close()
+	e := NewOTLPExporter(srv.URL, "upstairs", BatchOptions{})
+	for i := 0; i < 3; i++ {
+		e.Export(&NetGearLog{EventType: eventAdminLogin})
+	}
+	if err := e.Flush(); err == nil {
+		t.Error("Expected partial success to be reported")
+	}
+	if e.Dropped() != 1 || len(e.b.buf) != 0 {
+		t.Errorf("Expected only the rejected record dropped and the batch delivered, got %d dropped and %d buffered", e.Dropped(), len(e.b.buf))
+	}
+	if err := e.Flush(); err != nil {
+		t.Errorf("Expected the partial success to be reported once, got %v", err)
+	}
+}
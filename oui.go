@@ -0,0 +1,193 @@
+package netgearlogs
+
+import (
+	"bufio"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ouiSnapshot is a small extract of the IEEE registry in its CSV format, covering the
+// devices seen in the sample log and a few common vendors. It is used when no full
+// registry file is available.
+//
+//go:embed oui_snapshot.csv
+var ouiSnapshot string
+
+// LocallyAdministered is returned by OUIDatabase.Vendor for MAC addresses with the
+// locally administered bit set. Such addresses are not assigned by the IEEE; phones and
+// laptops use them as randomised, private Wi-Fi addresses.
+const LocallyAdministered = "(locally administered)"
+
+// ouiPrefixLengths are the lengths, in hex digits, of IEEE MA-S, MA-M and MA-L
+// assignments, longest first.
+var ouiPrefixLengths = []int{9, 7, 6}
+
+// OUIDatabase maps MAC address prefixes to the organisations they are assigned to.
+type OUIDatabase struct {
+	// vendors maps an upper-case hex prefix of 6, 7 or 9 digits to its organisation.
+	vendors  map[string]string
+	fallback *OUIDatabase
+}
+
+var (
+	defaultOUIOnce sync.Once
+	defaultOUI     *OUIDatabase
+)
+
+// DefaultOUIDatabase returns the database built from the embedded registry snapshot.
+func DefaultOUIDatabase() *OUIDatabase {
+	defaultOUIOnce.Do(func() {
+		db, err := ParseOUI(strings.NewReader(ouiSnapshot))
+		if err != nil {
+			panic("netgearlogs: bad embedded OUI snapshot: " + err.Error())
+		}
+		defaultOUI = db
+	})
+	return defaultOUI
+}
+
+// OpenOUIDatabase loads the IEEE registry file at path, in either the oui.txt or the
+// oui.csv format; the MA-M and MA-S CSV files are accepted too. Prefixes missing from
+// the file are looked up in the embedded snapshot. Use DefaultOUIDatabase for the
+// snapshot on its own.
+func OpenOUIDatabase(path string) (*OUIDatabase, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	db, err := ParseOUI(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	db.fallback = DefaultOUIDatabase()
+	return db, nil
+}
+
+// ParseOUI reads a registry in the IEEE oui.txt or CSV format, telling them apart by
+// the CSV header line.
+func ParseOUI(r io.Reader) (*OUIDatabase, error) {
+	br := bufio.NewReader(r)
+	// The header may be preceded by a byte order mark.
+	head, err := br.Peek(len("\ufeffRegistry,"))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if strings.HasPrefix(strings.TrimPrefix(string(head), "\ufeff"), "Registry,") {
+		return parseOUICSV(br)
+	}
+	return parseOUIText(br)
+}
+
+func parseOUICSV(r io.Reader) (*OUIDatabase, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	db := &OUIDatabase{vendors: make(map[string]string)}
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return db, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 {
+			continue
+		}
+		if len(rec) < 3 {
+			return nil, fmt.Errorf("line %d: expected at least 3 fields, got %d", line, len(rec))
+		}
+		prefix, ok := ouiPrefix(rec[1])
+		if !ok {
+			return nil, fmt.Errorf("line %d: bad assignment %q", line, rec[1])
+		}
+		db.vendors[prefix] = strings.TrimSpace(rec[2])
+	}
+}
+
+// parseOUIText reads the oui.txt format, taking the "(hex)" line of each entry:
+//
+//	10-A5-D0   (hex)		Murata Manufacturing Co., Ltd.
+func parseOUIText(r io.Reader) (*OUIDatabase, error) {
+	s := bufio.NewScanner(r)
+	db := &OUIDatabase{vendors: make(map[string]string)}
+	for s.Scan() {
+		line := s.Text()
+		i := strings.Index(line, "(hex)")
+		if i < 0 {
+			continue
+		}
+		prefix, ok := ouiPrefix(line[:i])
+		if !ok {
+			continue
+		}
+		db.vendors[prefix] = strings.TrimSpace(line[i+len("(hex)"):])
+	}
+	return db, s.Err()
+}
+
+// ouiPrefix returns s as an upper-case string of 6, 7 or 9 hex digits, ignoring
+// separators.
+func ouiPrefix(s string) (string, bool) {
+	s = strings.ToUpper(strings.NewReplacer("-", "", ":", "", ".", "").Replace(strings.TrimSpace(s)))
+	switch len(s) {
+	case 6, 7, 9:
+	default:
+		return "", false
+	}
+	if _, err := strconv.ParseUint(s, 16, 64); err != nil {
+		return "", false
+	}
+	return s, true
+}
+
+// Len returns the number of prefixes in the database, not counting its fallback.
+func (db *OUIDatabase) Len() int {
+	return len(db.vendors)
+}
+
+// Lookup returns the organisation a MAC address is assigned to. It reports false for
+// addresses that are not in the database, are malformed, or are locally administered.
+func (db *OUIDatabase) Lookup(mac string) (string, bool) {
+	m, ok := normalizeMAC(mac)
+	if !ok || strings.Count(m, ":") != 5 || IsLocallyAdministered(m) {
+		return "", false
+	}
+	hex := strings.ToUpper(strings.Replace(m, ":", "", -1))
+	for d := db; d != nil; d = d.fallback {
+		for _, n := range ouiPrefixLengths {
+			if v, ok := d.vendors[hex[:n]]; ok {
+				return v, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Vendor returns the organisation the entry's ToMACAddress is assigned to,
+// LocallyAdministered for randomised and other locally administered addresses, or ""
+// if the entry has no MAC address or its vendor is unknown.
+func (db *OUIDatabase) Vendor(l *NetGearLog) string {
+	if IsLocallyAdministered(l.ToMACAddress) {
+		return LocallyAdministered
+	}
+	v, _ := db.Lookup(l.ToMACAddress)
+	return v
+}
+
+// IsLocallyAdministered reports whether mac has the locally administered bit set, the
+// second-lowest bit of its first octet. It reports false for malformed addresses.
+func IsLocallyAdministered(mac string) bool {
+	m, ok := normalizeMAC(mac)
+	if !ok {
+		return false
+	}
+	first, err := strconv.ParseUint(m[:2], 16, 8)
+	return err == nil && first&0x02 != 0
+}
@@ -0,0 +1,122 @@
+package netgearlogs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDefaultOUIDatabase(t *testing.T) {
+	db := DefaultOUIDatabase()
+	var tests = []struct {
+		mac    string
+		vendor string
+		ok     bool
+	}{
+		{"10:a5:d0:cd:fc:19", "Murata Manufacturing Co., Ltd.", true},
+		{"6C:71:D9:6B:7A:A0", "AzureWave Technology Inc.", true},
+		{"b4-b6-76-bf-19-8b", "Intel Corporate", true},
+		{"00:11:22:33:44:55", "", false},
+		{"12:a5:d0:cd:fc:19", "", false},
+		{"10:a5:d0", "", false},
+		{"not a mac", "", false},
+	}
+	for i, tt := range tests {
+		vendor, ok := db.Lookup(tt.mac)
+		if vendor != tt.vendor || ok != tt.ok {
+			t.Errorf("%d. %s: expected %q, %t, got %q, %t", i, tt.mac, tt.vendor, tt.ok, vendor, ok)
+		}
+	}
+	for _, line := range readLogLines(t) {
+		l, err := ParseNetGearLogLine(line)
+		if err == nil && l.ToMACAddress != "" && db.Vendor(l) == "" {
+			t.Errorf("Expected a vendor for %s", l.ToMACAddress)
+		}
+	}
+}
+
+func TestIsLocallyAdministered(t *testing.T) {
+	var tests = []struct {
+		mac  string
+		want bool
+	}{
+		{"10:a5:d0:cd:fc:19", false},
+		{"12:a5:d0:cd:fc:19", true},
+		{"DA:A1:19:00:00:01", true},
+		{"b4:b6:76:bf:19:8b", false},
+		{"", false},
+	}
+	for i, tt := range tests {
+		if got := IsLocallyAdministered(tt.mac); got != tt.want {
+			t.Errorf("%d. %s: expected %t, got %t", i, tt.mac, tt.want, got)
+		}
+	}
+	l := &NetGearLog{ToMACAddress: "da:a1:19:00:00:01"}
+	if v := DefaultOUIDatabase().Vendor(l); v != LocallyAdministered {
+		t.Errorf("Expected %q, got %q", LocallyAdministered, v)
+	}
+}
+
+func TestParseOUIText(t *testing.T) {
+	text := `OUI/MA-L                                                    Organization                                 
+company_id                                                  Organization                                 
+                                                            Address                                      
+
+00-22-72   (hex)		American Micro-Fuel Device Corp.
+002272     (base 16)		American Micro-Fuel Device Corp.
+				2181 Buchanan Loop
+				Ferndale  WA  98248
+				US
+
+10-A5-D0   (hex)		Example Vendor
+10A5D0     (base 16)		Example Vendor
+`
+	db, err := ParseOUI(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Len() != 2 {
+		t.Errorf("Expected 2 prefixes, got %d", db.Len())
+	}
+	if v, _ := db.Lookup("00:22:72:00:00:01"); v != "American Micro-Fuel Device Corp." {
+		t.Errorf("Unexpected vendor %q", v)
+	}
+	if v, _ := db.Lookup("10:a5:d0:cd:fc:19"); v != "Example Vendor" {
+		t.Errorf("Expected the file to take precedence, got %q", v)
+	}
+}
+
+func TestOpenOUIDatabase(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mam.csv")
+	csv := "\ufeffRegistry,Assignment,Organization Name,Organization Address\n" +
+		"MA-M,70B3D51,\"Example Devices, Inc.\",Somewhere\n" +
+		"MA-L,70B3D5,IEEE Registration Authority,\n"
+	if err := os.WriteFile(path, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenOUIDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := db.Lookup("70:b3:d5:1a:00:01"); v != "Example Devices, Inc." {
+		t.Errorf("Expected the longest prefix to match, got %q", v)
+	}
+	if v, _ := db.Lookup("70:b3:d5:2a:00:01"); v != "IEEE Registration Authority" {
+		t.Errorf("Expected the MA-L prefix to match, got %q", v)
+	}
+	if v, _ := db.Lookup("10:a5:d0:cd:fc:19"); v == "" {
+		t.Error("Expected a fallback to the embedded snapshot")
+	}
+
+	if _, err := OpenOUIDatabase(filepath.Join(dir, "missing.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected an error for a missing file, got %v", err)
+	}
+
+	bad := filepath.Join(dir, "bad.csv")
+	os.WriteFile(bad, []byte("Registry,Assignment,Organization Name\nMA-L,XYZ,Nobody\n"), 0644)
+	if _, err := OpenOUIDatabase(bad); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected an error for a bad assignment, got %v", err)
+	}
+}
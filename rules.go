@@ -0,0 +1,320 @@
+package netgearlogs
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Alert is raised by a Rule when it detects something worth a look.
+type Alert struct {
+	// Rule is the name of the rule that raised the alert.
+	Rule string
+	// Key identifies what the alert is about, such as the rule and the offending
+	// address, so that repeats of the same alert can be recognised.
+	Key  string
+	Time time.Time
+	// Severity runs from 0 to 10, as in the SIEM formats.
+	Severity int
+	Summary  string
+	// Source is the address or MAC address the alert is about.
+	Source string
+	// Logs are the entries that triggered the alert.
+	Logs []*NetGearLog
+}
+
+// Rule is a detector run over the event stream. Check is called with each entry in
+// time order and returns an alert, or nil if the entry raises none. Rules keep state
+// between calls and are not safe for concurrent use.
+type Rule interface {
+	Name() string
+	Check(l *NetGearLog) *Alert
+}
+
+// RuleEngine runs a set of rules over entries.
+type RuleEngine struct {
+	Rules []Rule
+}
+
+// NewRuleEngine returns an engine running rules, or DefaultRules if none are given.
+func NewRuleEngine(rules ...Rule) *RuleEngine {
+	if len(rules) == 0 {
+		rules = DefaultRules()
+	}
+	return &RuleEngine{Rules: rules}
+}
+
+// DefaultRules returns the built-in detectors with their default thresholds.
+func DefaultRules() []Rule {
+	return []Rule{NewWLANBruteForceRule(), NewAdminLoginRule(), NewRemoteAccessPortRule()}
+}
+
+// Check runs every rule over l and returns the alerts raised.
+func (e *RuleEngine) Check(l *NetGearLog) []Alert {
+	var alerts []Alert
+	for _, r := range e.Rules {
+		if a := r.Check(l); a != nil {
+			alerts = append(alerts, *a)
+		}
+	}
+	return alerts
+}
+
+// Run checks every entry from src, calling emit with each alert raised. Entries must
+// come in time order: sort them with SortLogs, or read them through a MergeSource.
+// Lines that cannot be parsed are skipped. Run returns at the end of src, or on the
+// first other error from src or emit.
+func (e *RuleEngine) Run(src LogSource, emit func(Alert) error) error {
+	for {
+		l, err := src.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if _, ok := err.(*ParseError); ok {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, a := range e.Check(l) {
+			if err := emit(a); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Defaults for WLANBruteForceRule.
+const (
+	DefaultWLANThreshold = 5
+	DefaultWLANWindow    = 10 * time.Minute
+)
+
+// WLANBruteForceRule raises an alert when one MAC address is rejected for incorrect
+// Wi-Fi security Threshold times within Window, a sign of password guessing. Once it
+// has alerted for an address it starts counting that address again from zero.
+// Addresses with no rejection within Window are forgotten.
+type WLANBruteForceRule struct {
+	Threshold int
+	Window    time.Duration
+
+	rejects map[string][]*NetGearLog
+	// swept is when rejects was last cleared of addresses outside the window.
+	swept time.Time
+}
+
+// NewWLANBruteForceRule returns a WLANBruteForceRule with the default thresholds.
+func NewWLANBruteForceRule() *WLANBruteForceRule {
+	return &WLANBruteForceRule{Threshold: DefaultWLANThreshold, Window: DefaultWLANWindow}
+}
+
+// Name returns "wlan-brute-force".
+func (r *WLANBruteForceRule) Name() string { return "wlan-brute-force" }
+
+// Check counts l if it is a WLAN rejection.
+func (r *WLANBruteForceRule) Check(l *NetGearLog) *Alert {
+	if l.Kind() != eventWLANRejectIncorrectSec {
+		return nil
+	}
+	if r.rejects == nil {
+		r.rejects = make(map[string][]*NetGearLog)
+	}
+	r.sweep(l.Time)
+	mac, ok := normalizeMAC(l.ToMACAddress)
+	if !ok {
+		mac = l.ToMACAddress
+	}
+	recent := append(r.rejects[mac], l)
+	for len(recent) > 0 && l.Time.Sub(recent[0].Time) > r.Window {
+		recent = recent[1:]
+	}
+	if len(recent) < r.Threshold {
+		r.rejects[mac] = recent
+		return nil
+	}
+	delete(r.rejects, mac)
+	return &Alert{
+		Rule:     r.Name(),
+		Key:      r.Name() + ":" + mac,
+		Time:     l.Time,
+		Severity: 7,
+		Summary:  fmt.Sprintf("%d WLAN rejections for incorrect security from %s within %s", len(recent), mac, l.Time.Sub(recent[0].Time)),
+		Source:   mac,
+		Logs:     recent,
+	}
+}
+
+// sweep forgets the addresses whose last rejection is over a window before now, at
+// most once a window so that the cost is spread over many entries.
+func (r *WLANBruteForceRule) sweep(now time.Time) {
+	if now.Sub(r.swept) <= r.Window {
+		return
+	}
+	for mac, recent := range r.rejects {
+		if now.Sub(recent[len(recent)-1].Time) > r.Window {
+			delete(r.rejects, mac)
+		}
+	}
+	r.swept = now
+}
+
+// DefaultMaxKnown is the default number of addresses AdminLoginRule and
+// RemoteAccessPortRule learn before forgetting the least recently seen.
+const DefaultMaxKnown = 4096
+
+// learn adds key to known as seen at t. If max keys have been learned already, the one
+// seen least recently is forgotten first. Keys the caller put in known are never
+// forgotten.
+func learn(known map[string]bool, learned map[string]time.Time, key string, t time.Time, max int) {
+	if known[key] {
+		if _, ok := learned[key]; ok {
+			learned[key] = t
+		}
+		return
+	}
+	if max <= 0 {
+		max = DefaultMaxKnown
+	}
+	if len(learned) >= max {
+		var oldest string
+		var oldestSeen time.Time
+		for k, seen := range learned {
+			if oldestSeen.IsZero() || seen.Before(oldestSeen) {
+				oldest, oldestSeen = k, seen
+			}
+		}
+		delete(learned, oldest)
+		delete(known, oldest)
+	}
+	known[key] = true
+	learned[key] = t
+}
+
+// privateNetworks are the IPv4 private address blocks of RFC 1918.
+var privateNetworks = []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"}
+
+func mustParseCIDRs(cidrs []string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// AdminLoginRule raises an alert when the router's admin interface is logged into
+// from outside the LAN, or from an address that has not logged in before.
+type AdminLoginRule struct {
+	// LAN are the networks that count as local. Defaults to the RFC 1918 blocks.
+	LAN []*net.IPNet
+	// Known are addresses expected to log in; they raise no alert unless outside the
+	// LAN. Addresses are added as they are seen.
+	Known map[string]bool
+	// MaxKnown bounds the addresses added to Known as they are seen. Defaults to
+	// DefaultMaxKnown.
+	MaxKnown int
+
+	learned map[string]time.Time
+}
+
+// NewAdminLoginRule returns an AdminLoginRule treating the private address blocks as
+// the LAN.
+func NewAdminLoginRule() *AdminLoginRule {
+	return &AdminLoginRule{LAN: mustParseCIDRs(privateNetworks), Known: make(map[string]bool), MaxKnown: DefaultMaxKnown}
+}
+
+// Name returns "admin-login".
+func (r *AdminLoginRule) Name() string { return "admin-login" }
+
+func (r *AdminLoginRule) inLAN(ip string) bool {
+	parsed := net.ParseIP(ip)
+	for _, n := range r.LAN {
+		if parsed != nil && n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check inspects l if it is an admin login.
+func (r *AdminLoginRule) Check(l *NetGearLog) *Alert {
+	if l.Kind() != eventAdminLogin {
+		return nil
+	}
+	if r.Known == nil {
+		r.Known = make(map[string]bool)
+	}
+	if r.learned == nil {
+		r.learned = make(map[string]time.Time)
+	}
+	ip := l.SourceIP()
+	known := r.Known[ip]
+	learn(r.Known, r.learned, ip, l.Time, r.MaxKnown)
+	a := &Alert{Rule: r.Name(), Key: r.Name() + ":" + ip, Time: l.Time, Source: ip, Logs: []*NetGearLog{l}}
+	switch {
+	case !r.inLAN(ip):
+		a.Severity = 9
+		a.Summary = fmt.Sprintf("admin login from %s, outside the LAN", ip)
+	case !known:
+		a.Severity = 5
+		a.Summary = fmt.Sprintf("admin login from new address %s", ip)
+	default:
+		return nil
+	}
+	return a
+}
+
+// RemoteAccessPortRule raises an alert the first time a LAN access from remote entry
+// reaches an internal address and port, which means a new service has been exposed
+// to the internet.
+type RemoteAccessPortRule struct {
+	// Known are the "address:port" destinations expected to be reached. Destinations
+	// are added as they are seen.
+	Known map[string]bool
+	// MaxKnown bounds the destinations added to Known as they are seen. Defaults to
+	// DefaultMaxKnown.
+	MaxKnown int
+
+	learned map[string]time.Time
+}
+
+// NewRemoteAccessPortRule returns a RemoteAccessPortRule that knows no destinations.
+func NewRemoteAccessPortRule() *RemoteAccessPortRule {
+	return &RemoteAccessPortRule{Known: make(map[string]bool), MaxKnown: DefaultMaxKnown}
+}
+
+// Name returns "remote-access-new-port".
+func (r *RemoteAccessPortRule) Name() string { return "remote-access-new-port" }
+
+// Check inspects l if it is a LAN access from remote.
+func (r *RemoteAccessPortRule) Check(l *NetGearLog) *Alert {
+	if l.Kind() != eventLANAccessFromRemote {
+		return nil
+	}
+	if r.Known == nil {
+		r.Known = make(map[string]bool)
+	}
+	if r.learned == nil {
+		r.learned = make(map[string]time.Time)
+	}
+	dest := net.JoinHostPort(l.DestHost(), strconv.Itoa(l.DestPort()))
+	known := r.Known[dest]
+	learn(r.Known, r.learned, dest, l.Time, r.MaxKnown)
+	if known {
+		return nil
+	}
+	return &Alert{
+		Rule:     r.Name(),
+		Key:      r.Name() + ":" + dest,
+		Time:     l.Time,
+		Severity: 6,
+		Summary:  fmt.Sprintf("remote access to new internal port %s from %s", dest, l.SourceIP()),
+		Source:   l.SourceIP(),
+		Logs:     []*NetGearLog{l},
+	}
+}
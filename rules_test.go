@@ -0,0 +1,139 @@
+package netgearlogs
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func wlanReject(mac string, at time.Time) *NetGearLog {
+	return &NetGearLog{Time: at, EventType: eventWLANRejectIncorrectSec, ToMACAddress: mac}
+}
+
+func TestWLANBruteForceRule(t *testing.T) {
+	r := NewWLANBruteForceRule()
+	r.Threshold = 3
+	base := time.Date(2016, 2, 17, 16, 0, 0, 0, time.UTC)
+	var alerts []*Alert
+	for _, l := range []*NetGearLog{
+		wlanReject("10:a5:d0:cd:fc:19", base),
+		wlanReject("10:a5:d0:cd:fc:19", base.Add(20*time.Minute)),
+		wlanReject("10:A5:D0:CD:FC:19", base.Add(21*time.Minute)),
+		wlanReject("aa:bb:cc:dd:ee:ff", base.Add(22*time.Minute)),
+		wlanReject("10:a5:d0:cd:fc:19", base.Add(25*time.Minute)),
+		wlanReject("10:a5:d0:cd:fc:19", base.Add(26*time.Minute)),
+	} {
+		if a := r.Check(l); a != nil {
+			alerts = append(alerts, a)
+		}
+	}
+	if len(alerts) != 1 {
+		t.Fatalf("Expected 1 alert, got %d", len(alerts))
+	}
+	a := alerts[0]
+	if a.Rule != "wlan-brute-force" || a.Source != "10:a5:d0:cd:fc:19" || len(a.Logs) != 3 || !a.Time.Equal(base.Add(25*time.Minute)) {
+		t.Errorf("Unexpected alert %+v", a)
+	}
+	if a.Key != "wlan-brute-force:10:a5:d0:cd:fc:19" {
+		t.Errorf("Unexpected key %q", a.Key)
+	}
+}
+
+func TestWLANBruteForceRuleForgets(t *testing.T) {
+	r := NewWLANBruteForceRule()
+	base := time.Date(2016, 2, 17, 16, 0, 0, 0, time.UTC)
+	// Randomised MAC addresses, each rejected once.
+	for i := 0; i < 100; i++ {
+		r.Check(wlanReject(fmt.Sprintf("02:00:00:00:00:%02x", i), base.Add(time.Duration(i)*time.Second)))
+	}
+	r.Check(wlanReject("10:a5:d0:cd:fc:19", base.Add(time.Hour)))
+	if len(r.rejects) != 1 {
+		t.Errorf("Expected addresses outside the window to be forgotten, %d remain", len(r.rejects))
+	}
+}
+
+func TestAdminLoginRule(t *testing.T) {
+	r := NewAdminLoginRule()
+	r.Known["192.168.1.6"] = true
+	logs := parseLines([]string{
+		"[admin login] from source 192.168.1.6, Wednesday, February 17, 2016 11:13:39",
+		"[admin login] from source 192.168.1.7, Wednesday, February 17, 2016 12:00:00",
+		"[admin login] from source 192.168.1.7, Wednesday, February 17, 2016 13:00:00",
+		"[admin login] from source 80.82.79.104, Wednesday, February 17, 2016 14:00:00",
+		"[admin login] from source 80.82.79.104, Wednesday, February 17, 2016 15:00:00",
+	})
+	var got []int
+	for _, l := range logs {
+		if a := r.Check(l); a != nil {
+			got = append(got, a.Severity)
+		}
+	}
+	if len(got) != 3 || got[0] != 5 || got[1] != 9 || got[2] != 9 {
+		t.Errorf("Expected a new-address alert then two outside-LAN alerts, got severities %v", got)
+	}
+}
+
+func TestRemoteAccessPortRule(t *testing.T) {
+	r := NewRemoteAccessPortRule()
+	logs := parseLines([]string{
+		"[LAN access from remote] from 66.240.219.146:34680 to 192.168.1.9:8080, Monday, February 22, 2016 12:40:36",
+		"[LAN access from remote] from 80.82.79.104:46589 to 192.168.1.9:8080, Monday, February 22, 2016 13:11:37",
+		"[LAN access from remote] from 80.82.79.104:46590 to 192.168.1.9:22, Monday, February 22, 2016 13:11:38",
+	})
+	var alerts []*Alert
+	for _, l := range logs {
+		if a := r.Check(l); a != nil {
+			alerts = append(alerts, a)
+		}
+	}
+	if len(alerts) != 2 || alerts[0].Key != "remote-access-new-port:192.168.1.9:8080" || alerts[1].Source != "80.82.79.104" {
+		t.Errorf("Unexpected alerts %+v", alerts)
+	}
+}
+
+func TestRemoteAccessPortRuleMaxKnown(t *testing.T) {
+	r := NewRemoteAccessPortRule()
+	r.MaxKnown = 2
+	r.Known["192.168.1.9:8080"] = true
+	base := time.Date(2016, 2, 22, 12, 0, 0, 0, time.UTC)
+	access := func(port, min int) *Alert {
+		return r.Check(&NetGearLog{Time: base.Add(time.Duration(min) * time.Minute), EventType: eventLANAccessFromRemote, FromSource: "80.82.79.104:46589", ToDest: "192.168.1.9:" + strconv.Itoa(port)})
+	}
+	access(22, 0)
+	access(23, 1)
+	access(22, 2)
+	// Port 23 was seen least recently, so it is forgotten to make room.
+	if access(25, 3) == nil || len(r.Known) != 3 {
+		t.Fatalf("Expected a new port alert and a bounded set, got %v", r.Known)
+	}
+	if !r.Known["192.168.1.9:8080"] || !r.Known["192.168.1.9:22"] || r.Known["192.168.1.9:23"] {
+		t.Errorf("Expected the least recently seen learned port to be forgotten, got %v", r.Known)
+	}
+}
+
+func TestRuleEngineRun(t *testing.T) {
+	logs := parseLines(readLogLines(t))
+	SortLogs(logs)
+	var alerts []Alert
+	err := NewRuleEngine().Run(NewSliceSource(logs), func(a Alert) error {
+		alerts = append(alerts, a)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := make(map[string]int)
+	for _, a := range alerts {
+		rules[a.Rule]++
+	}
+	if rules["remote-access-new-port"] == 0 || rules["admin-login"] == 0 {
+		t.Errorf("Expected remote access and admin login alerts from log.txt, got %v", rules)
+	}
+
+	stop := errors.New("stop")
+	if err := NewRuleEngine().Run(NewSliceSource(logs), func(Alert) error { return stop }); err != stop {
+		t.Errorf("Expected the emit error to be returned, got %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+package netgearlogs
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// siemEvent describes how an event kind is presented to a SIEM.
+type siemEvent struct {
+	id       int
+	name     string
+	severity int // 0 (lowest) to 10, as in CEF
+	category string
+}
+
+// siemEvents maps event kinds to their CEF signature ID, name and severity.
+var siemEvents = map[string]siemEvent{
+	eventDoSAttackSynAckScan:    {100, "DoS Attack: SYN/ACK Scan", 5, "dos"},
+	eventDoSAttackRstScan:       {101, "DoS Attack: RST Scan", 5, "dos"},
+	eventDoSAttackTCPUDPChargen: {102, "DoS Attack: TCP/UDP Chargen", 6, "dos"},
+	eventDoSAttackAckScan:       {103, "DoS Attack: ACK Scan", 5, "dos"},
+	eventDoSAttackICMPScan:      {104, "DoS Attack: ICMP Scan", 4, "dos"},
+	eventDoSAttackARPAttack:     {105, "DoS Attack: ARP Attack", 7, "dos"},
+	eventDoSAttackTCPUDPEcho:    {106, "DoS Attack: TCP/UDP Echo", 6, "dos"},
+	eventWLANRejectIncorrectSec: {200, "WLAN access rejected: incorrect security", 6, "wlan"},
+	eventLANAccessFromRemote:    {300, "LAN access from remote", 7, "firewall"},
+	eventAdminLogin:             {400, "Admin login", 5, "authentication"},
+	eventAccessControl:          {500, "Access Control", 3, "access"},
+	eventSiteAllowed:            {600, "Site allowed", 1, "web"},
+	eventSiteBlocked:            {601, "Site blocked", 3, "web"},
+	eventUPnPAddNatRule:         {700, "UPnP NAT rule added", 2, "upnp"},
+	eventUPnPDelNatRule:         {701, "UPnP NAT rule deleted", 1, "upnp"},
+	eventInternetConnected:      {800, "Internet connected", 2, "wan"},
+	eventDHCPIP:                 {900, "DHCP IP assigned", 1, "dhcp"},
+	eventTimeSyncNTP:            {901, "Time synchronized with NTP server", 0, "system"},
+	eventDynamicDNS:             {902, "Dynamic DNS registration", 1, "system"},
+	eventEmailSent:              {903, "Email sent", 0, "system"},
+	eventEmailFailed:            {904, "Email failed", 3, "system"},
+}
+
+// siemEventFor returns the SIEM description of the entry. Blocked Access Control
+// entries get their own signature and a higher severity than allowed ones.
+func siemEventFor(l *NetGearLog) siemEvent {
+	e, ok := siemEvents[l.Kind()]
+	if !ok {
+		return siemEvent{999, l.EventType, 1, "other"}
+	}
+	if l.Kind() == eventAccessControl && strings.HasSuffix(l.EventType, "blocked") {
+		e.id, e.name, e.severity = 501, "Access Control blocked", 6
+	}
+	return e
+}
+
+// siemAddresses returns the source and destination of the entry in the terms SIEM
+// formats use.
+func siemAddresses(l *NetGearLog) (src string, spt int, dst string, dpt int, smac string) {
+	smac = l.ToMACAddress
+	switch l.Kind() {
+	case eventDHCPIP:
+		// The address is handed to the device, so it is the destination.
+		return "", 0, l.FromSource, 0, smac
+	case eventLANAccessFromRemote:
+		return l.SourceIP(), l.SourcePort(), l.DestHost(), l.DestPort(), smac
+	}
+	return l.SourceIP(), l.SourcePort(), "", 0, smac
+}
+
+// siemDestHost returns the host name an entry refers to, if any.
+func siemDestHost(l *NetGearLog) string {
+	switch l.Kind() {
+	case eventEmailSent, eventDynamicDNS, eventSiteAllowed, eventSiteBlocked:
+		return l.ToDest
+	}
+	return ""
+}
+
+// CEFEncoder writes entries in ArcSight Common Event Format, one per line.
+type CEFEncoder struct {
+	// Product and Version identify the device in the CEF header.
+	Product string
+	Version string
+	// Syslog wraps each line in an RFC 3164 syslog header, sent as Hostname.
+	Syslog   bool
+	Hostname string
+
+	w io.Writer
+}
+
+// NewCEFEncoder returns a CEFEncoder writing to w.
+func NewCEFEncoder(w io.Writer) *CEFEncoder {
+	return &CEFEncoder{w: w, Product: "WNDR4300", Version: "1.0", Hostname: "netgear"}
+}
+
+// Encode writes a single entry.
+func (e *CEFEncoder) Encode(l *NetGearLog) error {
+	line := FormatCEF(l, e.Product, e.Version)
+	if e.Syslog {
+		line = syslogHeader(l, siemEventFor(l).severity, e.Hostname) + line
+	}
+	_, err := io.WriteString(e.w, line+"\n")
+	return err
+}
+
+// FormatCEF formats the entry as a CEF line for the given product and version.
+func FormatCEF(l *NetGearLog, product, version string) string {
+	ev := siemEventFor(l)
+	src, spt, dst, dpt, smac := siemAddresses(l)
+	var ext []string
+	add := func(key, value string) {
+		if value != "" && value != "0" {
+			ext = append(ext, key+"="+escapeCEFExtension(value))
+		}
+	}
+	add("rt", strconv.FormatInt(l.Time.UnixMilli(), 10))
+	add("src", src)
+	add("spt", strconv.Itoa(spt))
+	add("dst", dst)
+	add("dpt", strconv.Itoa(dpt))
+	add("smac", smac)
+	add("dhost", siemDestHost(l))
+	add("shost", l.Device)
+	add("cat", ev.category)
+	add("msg", l.Message)
+	return fmt.Sprintf("CEF:0|NETGEAR|%s|%s|%d|%s|%d|%s",
+		escapeCEFHeader(product), escapeCEFHeader(version), ev.id, escapeCEFHeader(ev.name), ev.severity,
+		strings.Join(ext, " "))
+}
+
+// escapeCEFHeader escapes backslashes and pipes, as required in CEF header fields.
+func escapeCEFHeader(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ").Replace(s)
+}
+
+// escapeCEFExtension escapes backslashes, equals signs and newlines, as required in
+// CEF extension values.
+func escapeCEFExtension(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r\n", `\n`, "\n", `\n`, "\r", `\r`).Replace(s)
+}
+
+// LEEFEncoder writes entries in IBM QRadar Log Event Extended Format 1.0, one per line.
+type LEEFEncoder struct {
+	// Product and Version identify the device in the LEEF header.
+	Product string
+	Version string
+	// Syslog wraps each line in an RFC 3164 syslog header, sent as Hostname.
+	Syslog   bool
+	Hostname string
+
+	w io.Writer
+}
+
+// NewLEEFEncoder returns a LEEFEncoder writing to w.
+func NewLEEFEncoder(w io.Writer) *LEEFEncoder {
+	return &LEEFEncoder{w: w, Product: "WNDR4300", Version: "1.0", Hostname: "netgear"}
+}
+
+// Encode writes a single entry.
+func (e *LEEFEncoder) Encode(l *NetGearLog) error {
+	line := FormatLEEF(l, e.Product, e.Version)
+	if e.Syslog {
+		line = syslogHeader(l, siemEventFor(l).severity, e.Hostname) + line
+	}
+	_, err := io.WriteString(e.w, line+"\n")
+	return err
+}
+
+// leefTimeFormat is the devTimeFormat sent with every LEEF event, in Java
+// SimpleDateFormat notation.
+const leefTimeFormat = "yyyy-MM-dd'T'HH:mm:ssZ"
+
+// FormatLEEF formats the entry as a LEEF 1.0 line for the given product and version.
+// Attributes are separated by tabs.
+func FormatLEEF(l *NetGearLog, product, version string) string {
+	ev := siemEventFor(l)
+	src, spt, dst, dpt, smac := siemAddresses(l)
+	sev := ev.severity
+	if sev < 1 {
+		sev = 1
+	}
+	var attrs []string
+	add := func(key, value string) {
+		if value != "" && value != "0" {
+			attrs = append(attrs, key+"="+escapeLEEFAttribute(value))
+		}
+	}
+	add("devTime", l.Time.Format("2006-01-02T15:04:05-0700"))
+	add("devTimeFormat", leefTimeFormat)
+	add("cat", ev.category)
+	add("sev", strconv.Itoa(sev))
+	add("src", src)
+	add("srcPort", strconv.Itoa(spt))
+	add("dst", dst)
+	add("dstPort", strconv.Itoa(dpt))
+	add("srcMAC", smac)
+	add("dstHost", siemDestHost(l))
+	add("srcHost", l.Device)
+	add("msg", l.Message)
+	return fmt.Sprintf("LEEF:1.0|NETGEAR|%s|%s|%d|%s",
+		escapeCEFHeader(product), escapeCEFHeader(version), ev.id, strings.Join(attrs, "\t"))
+}
+
+// escapeLEEFAttribute escapes characters that would break the tab-delimited
+// key=value attribute list.
+func escapeLEEFAttribute(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\r\n", `\n`, "\n", `\n`, "\r", `\r`, `=`, `\=`).Replace(s)
+}
+
+// syslogHeader returns an RFC 3164 header for the entry, using the local0 facility
+// and a syslog severity derived from the 0-10 SIEM severity.
+func syslogHeader(l *NetGearLog, severity int, hostname string) string {
+	const local0 = 16
+	return fmt.Sprintf("<%d>%s %s ", local0*8+syslogLevel(severity), l.Time.Format("Jan _2 15:04:05"), hostname)
+}
+
+// syslogLevel maps a 0-10 SIEM severity onto the syslog severity levels.
+func syslogLevel(severity int) int {
+	switch {
+	case severity >= 9:
+		return 2 // critical
+	case severity >= 7:
+		return 3 // error
+	case severity >= 5:
+		return 4 // warning
+	case severity >= 3:
+		return 5 // notice
+	}
+	return 6 // informational
+}
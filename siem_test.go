@@ -0,0 +1,90 @@
+package netgearlogs
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFormatCEF(t *testing.T) {
+	tests := map[string]string{
+		"[DoS Attack: SYN/ACK Scan] from source: 37.59.134.139, port 80, Monday, February 22, 2016 18:10:18":                                         "CEF:0|NETGEAR|WNDR4300|1.0|100|DoS Attack: SYN/ACK Scan|5|rt=1456164618000 src=37.59.134.139 spt=80 cat=dos",
+		"[LAN access from remote] from 80.82.79.104:46589 to 192.168.1.9:8080, Monday, February 22, 2016 13:11:37":                                   "CEF:0|NETGEAR|WNDR4300|1.0|300|LAN access from remote|7|rt=1456146697000 src=80.82.79.104 spt=46589 dst=192.168.1.9 dpt=8080 cat=firewall",
+		"[WLAN access rejected: incorrect security] from MAC address 10:a5:d0:cd:fc:19, Tuesday, February 16, 2016 17:43:13":                         "CEF:0|NETGEAR|WNDR4300|1.0|200|WLAN access rejected: incorrect security|6|rt=1455644593000 smac=10:a5:d0:cd:fc:19 cat=wlan",
+		"[Access Control] Device unknown with MAC address 6C:71:D9:6B:7A:A0 is blocked to access the network, Wednesday, February 17, 2016 11:08:30": "CEF:0|NETGEAR|WNDR4300|1.0|501|Access Control blocked|6|rt=1455707310000 smac=6C:71:D9:6B:7A:A0 shost=unknown cat=access",
+		"[admin login] from source 192.168.1.6, Wednesday, February 17, 2016 11:13:39":                                                               "CEF:0|NETGEAR|WNDR4300|1.0|400|Admin login|5|rt=1455707619000 src=192.168.1.6 cat=authentication",
+	}
+	for line, expected := range tests {
+		l, err := ParseNetGearLogLine(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := FormatCEF(l, "WNDR4300", "1.0"); got != expected {
+			t.Errorf("Expected\n%s\ngot\n%s", expected, got)
+		}
+	}
+}
+
+func TestCEFEscaping(t *testing.T) {
+	l := logAt("Weird|Event", "", "Monday, February 22, 2016 18:10:18")
+	l.Message = "a=b\\c\nd"
+	got := FormatCEF(l, "WNDR|4300", "1.0")
+	expected := `CEF:0|NETGEAR|WNDR\|4300|1.0|999|Weird\|Event|1|rt=1456164618000 cat=other msg=a\=b\\c\nd`
+	if got != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, got)
+	}
+}
+
+func TestFormatLEEF(t *testing.T) {
+	l, err := ParseNetGearLogLine("[LAN access from remote] from 80.82.79.104:46589 to 192.168.1.9:8080, Monday, February 22, 2016 13:11:37")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "LEEF:1.0|NETGEAR|WNDR4300|1.0|300|" + strings.Join([]string{
+		"devTime=2016-02-22T13:11:37+0000",
+		"devTimeFormat=yyyy-MM-dd'T'HH:mm:ssZ",
+		"cat=firewall",
+		"sev=7",
+		"src=80.82.79.104",
+		"srcPort=46589",
+		"dst=192.168.1.9",
+		"dstPort=8080",
+	}, "\t")
+	if got := FormatLEEF(l, "WNDR4300", "1.0"); got != expected {
+		t.Errorf("Expected\n%q\ngot\n%q", expected, got)
+	}
+
+	l = &NetGearLog{EventType: eventEmailFailed, Message: "tab\there"}
+	if got := FormatLEEF(l, "WNDR4300", "1.0"); !strings.HasSuffix(got, `msg=tab\there`) {
+		t.Errorf("Expected escaped tab, got %q", got)
+	}
+}
+
+func TestSyslogWrappedEncoders(t *testing.T) {
+	l, err := ParseNetGearLogLine("[DoS Attack: ARP Attack] from source: 192.168.1.14, Wednesday, February 17, 2016 11:57:47")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	cef := NewCEFEncoder(&buf)
+	cef.Syslog = true
+	cef.Hostname = "router"
+	if err := cef.Encode(l); err != nil {
+		t.Fatal(err)
+	}
+	leef := NewLEEFEncoder(&buf)
+	leef.Syslog = true
+	leef.Hostname = "router"
+	if err := leef.Encode(l); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d", len(lines))
+	}
+	for i, prefix := range []string{"<131>Feb 17 11:57:47 router CEF:0|", "<131>Feb 17 11:57:47 router LEEF:1.0|"} {
+		if !strings.HasPrefix(lines[i], prefix) {
+			t.Errorf("Expected %q to start with %q", lines[i], prefix)
+		}
+	}
+}
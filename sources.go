@@ -0,0 +1,359 @@
+package netgearlogs
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LogSource is implemented by anything that yields NetGearLog entries one at a time.
+// Next returns io.EOF once the source is exhausted. A line that cannot be parsed is
+// reported as a *ParseError, after which Next may be called again to continue.
+type LogSource interface {
+	Next() (*NetGearLog, error)
+}
+
+// ParseError records a log line that could not be parsed, along with where it came from.
+type ParseError struct {
+	File string
+	Line int
+	Text string
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	if e.File != "" {
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+// LogReader is a LogSource that parses entries from a single io.Reader.
+type LogReader struct {
+	s    *bufio.Scanner
+	file string
+	line int
+}
+
+// NewLogReader returns a LogReader reading log lines from r.
+func NewLogReader(r io.Reader) *LogReader {
+	return &LogReader{s: bufio.NewScanner(r)}
+}
+
+// Next parses and returns the next non-blank log line.
+func (r *LogReader) Next() (*NetGearLog, error) {
+	for r.s.Scan() {
+		r.line++
+		t := r.s.Text()
+		if strings.TrimSpace(t) == "" {
+			continue
+		}
+		log, err := ParseNetGearLogLine(t)
+		if err != nil {
+			return nil, &ParseError{File: r.file, Line: r.line, Text: t, Err: err}
+		}
+		log.File = r.file
+		return log, nil
+	}
+	if err := r.s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// SliceSource is a LogSource over entries that have already been parsed. Nil entries
+// are skipped.
+type SliceSource struct {
+	logs []*NetGearLog
+}
+
+// NewSliceSource returns a SliceSource yielding logs in order.
+func NewSliceSource(logs []*NetGearLog) *SliceSource {
+	return &SliceSource{logs: logs}
+}
+
+// Next returns the next entry in the slice.
+func (s *SliceSource) Next() (*NetGearLog, error) {
+	for len(s.logs) > 0 {
+		log := s.logs[0]
+		s.logs = s.logs[1:]
+		if log != nil {
+			return log, nil
+		}
+	}
+	return nil, io.EOF
+}
+
+// LogSet is a set of log files, such as a directory of rotated router exports, read
+// as a single stream. Files compressed with gzip or bzip2 are decompressed
+// transparently based on their magic bytes.
+//
+// Files are read oldest first, but the entries within each file stay newest first, as
+// the router writes them, so the stream as a whole is not in time order. Sort the
+// entries, or pass the files' entries to DedupLogs, before analysis that needs it.
+//
+// A LogSet can be handed to ParseNetGearLog as a plain io.Reader, or consumed entry by
+// entry through Next, in which case every entry is tagged with the file it came from.
+// The two styles should not be mixed on the same LogSet.
+type LogSet struct {
+	// Files lists the expanded paths in the order they are read: oldest to newest.
+	Files []string
+
+	idx int
+	f   *os.File
+	// gz is the gzip reader rd wraps, if the current file is gzipped.
+	gz   *gzip.Reader
+	rd   io.Reader
+	lr   *LogReader
+	last byte
+}
+
+// OpenLogs expands the given paths into a LogSet. Each path may be a file, a directory
+// (whose regular files are all included) or a glob pattern. The resulting files are
+// ordered oldest to newest using their rotation suffix (router.log.2.gz is older than
+// router.log.1, which is older than router.log), falling back on modification time.
+func OpenLogs(paths ...string) (*LogSet, error) {
+	var files []rotatedFile
+	seen := make(map[string]bool)
+	add := func(p string) error {
+		if seen[p] {
+			return nil
+		}
+		fi, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		seen[p] = true
+		files = append(files, rotatedFile{path: p, rotation: rotationIndex(p), modTime: fi.ModTime().UnixNano()})
+		return nil
+	}
+	for _, p := range paths {
+		var matches []string
+		if strings.ContainsAny(p, "*?[") {
+			m, err := filepath.Glob(p)
+			if err != nil {
+				return nil, err
+			}
+			if len(m) == 0 {
+				return nil, fmt.Errorf("no files match %s", p)
+			}
+			matches = m
+		} else {
+			matches = []string{p}
+		}
+		for _, m := range matches {
+			fi, err := os.Stat(m)
+			if err != nil {
+				return nil, err
+			}
+			if !fi.IsDir() {
+				if err := add(m); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			entries, err := os.ReadDir(m)
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range entries {
+				if err := add(filepath.Join(m, e.Name())); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		if a.rotation != b.rotation {
+			return a.rotation > b.rotation
+		}
+		if a.modTime != b.modTime {
+			return a.modTime < b.modTime
+		}
+		return a.path < b.path
+	})
+	set := &LogSet{}
+	for _, f := range files {
+		set.Files = append(set.Files, f.path)
+	}
+	return set, nil
+}
+
+// ParseNetGearLogFiles opens the given paths with OpenLogs and parses every entry in
+// them, oldest file first. Errors are keyed by log line, as with ParseNetGearLog.
+func ParseNetGearLogFiles(paths ...string) ([]*NetGearLog, map[string]error, error) {
+	set, err := OpenLogs(paths...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer set.Close()
+	var logs []*NetGearLog
+	errors := make(map[string]error)
+	for {
+		log, err := set.Next()
+		if err == io.EOF {
+			break
+		}
+		if perr, ok := err.(*ParseError); ok {
+			errors[perr.Text] = perr
+			continue
+		}
+		if err != nil {
+			return logs, errors, err
+		}
+		logs = append(logs, log)
+	}
+	return logs, errors, nil
+}
+
+// Read implements io.Reader, concatenating the files of the set. A newline is
+// inserted between files that do not end with one so lines never run together.
+func (s *LogSet) Read(p []byte) (int, error) {
+	for {
+		if s.rd == nil {
+			if s.idx >= len(s.Files) {
+				return 0, io.EOF
+			}
+			if err := s.open(); err != nil {
+				return 0, err
+			}
+		}
+		n, err := s.rd.Read(p)
+		if n > 0 {
+			s.last = p[n-1]
+			return n, nil
+		}
+		if err == io.EOF {
+			s.closeCurrent()
+			if s.last != '\n' && s.last != 0 {
+				s.last = '\n'
+				if len(p) == 0 {
+					return 0, nil
+				}
+				p[0] = '\n'
+				return 1, nil
+			}
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Next returns the next entry in the set, tagged with the file it was read from.
+func (s *LogSet) Next() (*NetGearLog, error) {
+	for {
+		if s.lr == nil {
+			if s.idx >= len(s.Files) {
+				return nil, io.EOF
+			}
+			if err := s.open(); err != nil {
+				return nil, err
+			}
+			s.lr = NewLogReader(s.rd)
+			s.lr.file = s.Files[s.idx-1]
+		}
+		log, err := s.lr.Next()
+		if err == io.EOF {
+			s.closeCurrent()
+			continue
+		}
+		return log, err
+	}
+}
+
+// Close closes the file currently being read, if any.
+func (s *LogSet) Close() error {
+	return s.closeCurrent()
+}
+
+// closeCurrent closes the current file and its gzip reader, if any.
+func (s *LogSet) closeCurrent() error {
+	if s.f == nil {
+		return nil
+	}
+	var err error
+	if s.gz != nil {
+		err = s.gz.Close()
+	}
+	if cerr := s.f.Close(); err == nil {
+		err = cerr
+	}
+	s.f, s.gz, s.rd, s.lr = nil, nil, nil, nil
+	return err
+}
+
+func (s *LogSet) open() error {
+	name := s.Files[s.idx]
+	s.idx++
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	rd, err := decompress(f)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("%s: %s", name, err)
+	}
+	s.f, s.rd = f, rd
+	s.gz, _ = rd.(*gzip.Reader)
+	return nil
+}
+
+var (
+	magicGzip  = []byte{0x1f, 0x8b}
+	magicBzip2 = []byte("BZh")
+)
+
+// decompress wraps r in a gzip or bzip2 reader if its leading bytes say it is compressed.
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(3)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(head, magicGzip):
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(head, magicBzip2):
+		return bzip2.NewReader(br), nil
+	}
+	return br, nil
+}
+
+type rotatedFile struct {
+	path     string
+	rotation int
+	modTime  int64
+}
+
+// rotationIndex returns N for names such as router.log.N or router.log.N.gz, and 0
+// for the live file.
+func rotationIndex(path string) int {
+	name := filepath.Base(path)
+	for _, ext := range []string{".gz", ".bz2"} {
+		name = strings.TrimSuffix(name, ext)
+	}
+	i := strings.LastIndex(name, ".")
+	if i < 0 {
+		return 0
+	}
+	n, err := strconv.Atoi(name[i+1:])
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
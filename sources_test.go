@@ -0,0 +1,155 @@
+package netgearlogs
+
+import (
+	"compress/gzip"
+	"encoding/base64"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// A bzip2-compressed copy of a single RST Scan line; the standard library cannot write bzip2.
+const bzip2RSTScan = "QlpoOTFBWSZTWQ6TMnUAAB/fgAAQQAV/8CUCHAo/C94gIABUY9I08kNAAAM1G1CKemmoNDQ9T1AAADni3720gO7AuHx0OSpYaQQZ0QGmyBSA2qQSCIykzZ0sTOAjPlWep7OQ2jAyF9CmrB4nBCqQknWy1FTAv8XckU4UJAOkzJ1A"
+
+func writeRotatedLogs(t *testing.T) string {
+	dir := t.TempDir()
+	write := func(name string, data []byte) {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("router.log", []byte("[admin login] from source 192.168.1.6, Tuesday, February 23, 2016 19:06:07\n"))
+	// No trailing newline: the next file must not run into this line.
+	write("router.log.1", []byte("[Time synchronized with NTP server] Monday, February 22, 2016 19:03:16"))
+
+	var gz strings.Builder
+	zw := gzip.NewWriter(&gz)
+	io.WriteString(zw, "[Internet connected] IP address: 96.37.90.24, Monday, February 22, 2016 17:02:59\n")
+	zw.Close()
+	write("router.log.2.gz", []byte(gz.String()))
+
+	bz, err := base64.StdEncoding.DecodeString(bzip2RSTScan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write("router.log.3.bz2", bz)
+	return dir
+}
+
+func TestOpenLogsOrdersRotatedFiles(t *testing.T) {
+	dir := writeRotatedLogs(t)
+	set, err := OpenLogs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer set.Close()
+	expected := []string{"router.log.3.bz2", "router.log.2.gz", "router.log.1", "router.log"}
+	if len(set.Files) != len(expected) {
+		t.Fatalf("Expected %d files, got %v", len(expected), set.Files)
+	}
+	for i, f := range set.Files {
+		if filepath.Base(f) != expected[i] {
+			t.Errorf("File %d: expected %s, got %s", i, expected[i], f)
+		}
+	}
+}
+
+func TestLogSetNextTagsFile(t *testing.T) {
+	dir := writeRotatedLogs(t)
+	set, err := OpenLogs(filepath.Join(dir, "router.log*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer set.Close()
+	expected := []struct {
+		event, file string
+	}{
+		{eventDoSAttackRstScan, "router.log.3.bz2"},
+		{eventInternetConnected, "router.log.2.gz"},
+		{eventTimeSyncNTP, "router.log.1"},
+		{eventAdminLogin, "router.log"},
+	}
+	for _, e := range expected {
+		l, err := set.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l.EventType != e.event || filepath.Base(l.File) != e.file {
+			t.Errorf("Expected %s from %s, got %s from %s", e.event, e.file, l.EventType, l.File)
+		}
+	}
+	if _, err := set.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+}
+
+func TestLogSetClosesGzipReader(t *testing.T) {
+	set, err := OpenLogs(writeRotatedLogs(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The second entry comes from the gzipped file.
+	for i := 0; i < 2; i++ {
+		if _, err := set.Next(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if set.gz == nil {
+		t.Fatal("Expected the gzipped file to be open")
+	}
+	if err := set.Close(); err != nil || set.gz != nil || set.f != nil {
+		t.Errorf("Expected the gzip reader and file to be closed, got %v", err)
+	}
+}
+
+func TestLogSetAsReader(t *testing.T) {
+	dir := writeRotatedLogs(t)
+	set, err := OpenLogs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer set.Close()
+	logs, errors := ParseNetGearLog(set)
+	for k, v := range errors {
+		t.Errorf("Error in %s: %s", k, v)
+	}
+	if len(logs) != 4 {
+		t.Errorf("Expected 4 entries, got %d", len(logs))
+	}
+}
+
+func TestLogReaderParseError(t *testing.T) {
+	r := NewLogReader(strings.NewReader("garbage\n\n[admin login] from source 192.168.1.6, Tuesday, February 23, 2016 19:06:07\n"))
+	_, err := r.Next()
+	perr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("Expected *ParseError, got %v", err)
+	}
+	if perr.Line != 1 || perr.Text != "garbage" {
+		t.Errorf("Unexpected error position: %+v", perr)
+	}
+	l, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.EventType != eventAdminLogin {
+		t.Errorf("Expected %s, got %s", eventAdminLogin, l.EventType)
+	}
+}
+
+func TestRotationIndex(t *testing.T) {
+	tests := map[string]int{
+		"router.log":           0,
+		"router.log.1":         1,
+		"/var/log/r.log.12.gz": 12,
+		"router.log.3.bz2":     3,
+		"log.txt":              0,
+	}
+	for name, n := range tests {
+		if got := rotationIndex(name); got != n {
+			t.Errorf("%s: expected %d, got %d", name, n, got)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"time"
+
+	netgearlogs "github.com/klauern/go-netgearlogs"
+)
+
+// Each record is framed as a 4-byte little-endian payload length, a 4-byte CRC-32C of
+// the payload, then the payload itself. The payload starts with a format version byte
+// followed by the time as a varint of Unix nanoseconds, the port as a uvarint, and the
+// string fields, each prefixed by its uvarint length.
+const (
+	recordHeaderSize = 8
+	recordVersion    = 1
+	// maxRecordSize guards against reading a garbage length from a damaged file, so
+	// larger records are refused when writing.
+	maxRecordSize = 1 << 20
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// errCorrupt reports a record whose checksum or framing is wrong.
+var errCorrupt = errors.New("corrupt record")
+
+// ErrRecordTooLarge is returned by Store.Append for an entry whose encoded record
+// would be larger than the store can read back.
+var ErrRecordTooLarge = errors.New("storage: record too large")
+
+// payloadSize returns the size of the payload encodeRecord writes for l.
+func payloadSize(l *netgearlogs.NetGearLog) int {
+	var tmp [binary.MaxVarintLen64]byte
+	n := 1 + binary.PutVarint(tmp[:], l.Time.UnixNano()) + binary.PutUvarint(tmp[:], uint64(l.Port))
+	for _, s := range []string{l.EventType, l.FromSource, l.ToDest, l.ToMACAddress, l.Device, l.Message, l.File, l.Raw} {
+		n += binary.PutUvarint(tmp[:], uint64(len(s))) + len(s)
+	}
+	return n
+}
+
+func encodeRecord(dst []byte, l *netgearlogs.NetGearLog) []byte {
+	start := len(dst)
+	dst = append(dst, make([]byte, recordHeaderSize)...)
+	dst = append(dst, recordVersion)
+	dst = binary.AppendVarint(dst, l.Time.UnixNano())
+	dst = binary.AppendUvarint(dst, uint64(l.Port))
+	for _, s := range []string{l.EventType, l.FromSource, l.ToDest, l.ToMACAddress, l.Device, l.Message, l.File, l.Raw} {
+		dst = binary.AppendUvarint(dst, uint64(len(s)))
+		dst = append(dst, s...)
+	}
+	payload := dst[start+recordHeaderSize:]
+	binary.LittleEndian.PutUint32(dst[start:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(dst[start+4:], crc32.Checksum(payload, crcTable))
+	return dst
+}
+
+// readRecord reads the record at off, returning it and its total size on disk.
+// A record cut short by the end of the file is reported as io.ErrUnexpectedEOF.
+func readRecord(r io.ReaderAt, off int64) (*netgearlogs.NetGearLog, int64, error) {
+	var hdr [recordHeaderSize]byte
+	if n, err := r.ReadAt(hdr[:], off); err != nil {
+		if err == io.EOF && n == 0 {
+			return nil, 0, io.EOF
+		}
+		if err == io.EOF {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		return nil, 0, err
+	}
+	size := binary.LittleEndian.Uint32(hdr[:4])
+	if size == 0 || size > maxRecordSize {
+		return nil, 0, errCorrupt
+	}
+	payload := make([]byte, size)
+	if _, err := r.ReadAt(payload, off+recordHeaderSize); err != nil {
+		if err == io.EOF {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		return nil, 0, err
+	}
+	if crc32.Checksum(payload, crcTable) != binary.LittleEndian.Uint32(hdr[4:]) {
+		return nil, 0, errCorrupt
+	}
+	l, err := decodePayload(payload)
+	if err != nil {
+		return nil, 0, err
+	}
+	return l, recordHeaderSize + int64(size), nil
+}
+
+func decodePayload(p []byte) (*netgearlogs.NetGearLog, error) {
+	if len(p) == 0 || p[0] != recordVersion {
+		return nil, errCorrupt
+	}
+	p = p[1:]
+	ns, n := binary.Varint(p)
+	if n <= 0 {
+		return nil, errCorrupt
+	}
+	p = p[n:]
+	port, n := binary.Uvarint(p)
+	if n <= 0 {
+		return nil, errCorrupt
+	}
+	p = p[n:]
+	var fields [8]string
+	for i := range fields {
+		size, n := binary.Uvarint(p)
+		if n <= 0 || uint64(len(p)-n) < size {
+			return nil, errCorrupt
+		}
+		fields[i] = string(p[n : n+int(size)])
+		p = p[n+int(size):]
+	}
+	return &netgearlogs.NetGearLog{
+		Time:         time.Unix(0, ns).UTC(),
+		Port:         int(port),
+		EventType:    fields[0],
+		FromSource:   fields[1],
+		ToDest:       fields[2],
+		ToMACAddress: fields[3],
+		Device:       fields[4],
+		Message:      fields[5],
+		File:         fields[6],
+		Raw:          fields[7],
+	}, nil
+}
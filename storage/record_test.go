@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	netgearlogs "github.com/klauern/go-netgearlogs"
+)
+
+func TestRecordRoundTrip(t *testing.T) {
+	line := "[DoS Attack: TCP/UDP Chargen] from source: 185.94.111.1, port 52792, Monday, February 22, 2016 17:46:56"
+	l, err := netgearlogs.ParseNetGearLogLine(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.File = "log.txt"
+	buf := encodeRecord(nil, l)
+	got, n, err := readRecord(bytes.NewReader(buf), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(buf)) {
+		t.Errorf("Expected record size %d, got %d", len(buf), n)
+	}
+	if size := recordHeaderSize + payloadSize(l); size != len(buf) {
+		t.Errorf("Expected payloadSize to match the encoded record, got %d for %d", size, len(buf))
+	}
+	if !got.Time.Equal(l.Time) {
+		t.Errorf("Expected time %s, got %s", l.Time, got.Time)
+	}
+	got.Time = l.Time
+	if !reflect.DeepEqual(got, l) {
+		t.Errorf("Expected %+v, got %+v", l, got)
+	}
+}
+
+func TestReadRecordDamaged(t *testing.T) {
+	buf := encodeRecord(nil, &netgearlogs.NetGearLog{Time: time.Unix(1526440300, 0), EventType: "Internet connected"})
+	if _, _, err := readRecord(bytes.NewReader(nil), 0); err != io.EOF {
+		t.Errorf("Expected io.EOF for an empty file, got %v", err)
+	}
+	if _, _, err := readRecord(bytes.NewReader(buf[:len(buf)-3]), 0); err != io.ErrUnexpectedEOF {
+		t.Errorf("Expected io.ErrUnexpectedEOF for a torn record, got %v", err)
+	}
+	flipped := append([]byte(nil), buf...)
+	flipped[len(flipped)-1] ^= 0xff
+	if _, _, err := readRecord(bytes.NewReader(flipped), 0); err != errCorrupt {
+		t.Errorf("Expected errCorrupt for a bad checksum, got %v", err)
+	}
+}
@@ -0,0 +1,535 @@
+// Package storage persists NetGear log entries in an embedded, append-only store so
+// they can be queried by time range and event kind without re-parsing exports.
+//
+// Entries are written to numbered segment files as checksummed records. On Open the
+// segments are scanned to rebuild the in-memory time and kind indexes; a record torn
+// by a crash at the end of the newest segment is detected by its checksum and cut off.
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	netgearlogs "github.com/klauern/go-netgearlogs"
+)
+
+const segmentPrefix, segmentSuffix = "segment-", ".ngl"
+
+// DefaultMaxSegmentSize is the size at which a new segment file is started.
+const DefaultMaxSegmentSize = 64 << 20
+
+// Options configure a Store. The zero value is usable.
+type Options struct {
+	// MaxSegmentSize is the size at which a new segment is started. Defaults to
+	// DefaultMaxSegmentSize.
+	MaxSegmentSize int64
+	// Retention is how long entries are kept by Compact. Zero keeps everything.
+	Retention time.Duration
+	// SyncWrites makes every Append fsync before returning.
+	SyncWrites bool
+}
+
+// Store is an append-only store of log entries. It is safe for concurrent use.
+type Store struct {
+	dir  string
+	opts Options
+	now  func() time.Time
+
+	mu       sync.RWMutex
+	segments map[int]*segment
+	active   *segment
+	byTime   []ref
+	byKind   map[string][]ref
+	seq      uint64
+}
+
+// segment is one file of records.
+type segment struct {
+	id      int
+	f       *os.File
+	size    int64
+	min     time.Time
+	max     time.Time
+	entries int
+}
+
+// ref locates a record. seq preserves append order between records with equal times.
+type ref struct {
+	t   int64
+	seq uint64
+	seg int
+	off int64
+}
+
+func (r ref) less(o ref) bool {
+	if r.t != o.t {
+		return r.t < o.t
+	}
+	return r.seq < o.seq
+}
+
+// Open opens the store in dir, creating the directory if needed, and rebuilds its
+// indexes from the segment files.
+func Open(dir string, opts *Options) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	s := &Store{dir: dir, now: time.Now}
+	if opts != nil {
+		s.opts = *opts
+	}
+	if s.opts.MaxSegmentSize <= 0 {
+		s.opts.MaxSegmentSize = DefaultMaxSegmentSize
+	}
+	if err := s.removeTemp(); err != nil {
+		return nil, err
+	}
+	if err := s.load(); err != nil {
+		s.closeFiles()
+		return nil, err
+	}
+	return s, nil
+}
+
+// removeTemp deletes segment copies left behind by a compaction that crashed before
+// renaming them into place.
+func (s *Store) removeTemp() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, segmentPrefix) && strings.HasSuffix(name, segmentSuffix+".tmp") {
+			if err := os.Remove(filepath.Join(s.dir, name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Store) segmentPath(id int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%s%08d%s", segmentPrefix, id, segmentSuffix))
+}
+
+// segmentIDs lists the ids of the segment files in the store directory, in order.
+func (s *Store) segmentIDs() ([]int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, segmentPrefix) || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		id, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, segmentPrefix), segmentSuffix))
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// load scans every segment and rebuilds the indexes. A damaged tail on the newest
+// segment is truncated; damage anywhere else is an error.
+func (s *Store) load() error {
+	s.segments = make(map[int]*segment)
+	s.byTime = nil
+	s.byKind = make(map[string][]ref)
+	s.seq = 0
+	ids, err := s.segmentIDs()
+	if err != nil {
+		return err
+	}
+	var refs []kindRef
+	for i, id := range ids {
+		last := i == len(ids)-1
+		flag := os.O_RDONLY
+		if last {
+			flag = os.O_RDWR
+		}
+		f, err := os.OpenFile(s.segmentPath(id), flag, 0644)
+		if err != nil {
+			return err
+		}
+		seg := &segment{id: id, f: f}
+		s.segments[id] = seg
+		if refs, err = s.scan(seg, last, refs); err != nil {
+			return err
+		}
+		if last {
+			s.active = seg
+		}
+	}
+	s.addRefs(refs)
+	if s.active == nil {
+		next := 1
+		if len(ids) > 0 {
+			next = ids[len(ids)-1] + 1
+		}
+		return s.newSegment(next)
+	}
+	return nil
+}
+
+// scan appends a ref to every record in seg to refs. If truncate is set, a torn or
+// corrupt tail is cut off instead of being reported.
+func (s *Store) scan(seg *segment, truncate bool, refs []kindRef) ([]kindRef, error) {
+	var off int64
+	for {
+		l, n, err := readRecord(seg.f, off)
+		if err == io.EOF {
+			break
+		}
+		if err == io.ErrUnexpectedEOF || err == errCorrupt {
+			if !truncate {
+				return refs, fmt.Errorf("%s: %s at offset %d", seg.f.Name(), err, off)
+			}
+			if err := seg.f.Truncate(off); err != nil {
+				return refs, err
+			}
+			if err := seg.f.Sync(); err != nil {
+				return refs, err
+			}
+			break
+		}
+		if err != nil {
+			return refs, err
+		}
+		refs = append(refs, s.index(seg, l, off))
+		off += n
+	}
+	seg.size = off
+	return refs, nil
+}
+
+// kindRef is a ref waiting to be added to the indexes, with the kind of its entry.
+type kindRef struct {
+	ref
+	kind string
+}
+
+// index accounts for l in seg's bounds and returns its ref. The ref is not in the
+// indexes until it is passed to addRefs.
+func (s *Store) index(seg *segment, l *netgearlogs.NetGearLog, off int64) kindRef {
+	s.seq++
+	if seg.entries == 0 || l.Time.Before(seg.min) {
+		seg.min = l.Time
+	}
+	if seg.entries == 0 || l.Time.After(seg.max) {
+		seg.max = l.Time
+	}
+	seg.entries++
+	return kindRef{ref{t: l.Time.UnixNano(), seq: s.seq, seg: seg.id, off: off}, l.Kind()}
+}
+
+// addRefs sorts refs and merges them into the indexes, so that a batch in any order,
+// such as a newest-first export, costs one sort and one pass over each index.
+func (s *Store) addRefs(refs []kindRef) {
+	sort.Slice(refs, func(i, j int) bool { return refs[i].less(refs[j].ref) })
+	sorted := make([]ref, len(refs))
+	byKind := make(map[string][]ref)
+	for i, r := range refs {
+		sorted[i] = r.ref
+		byKind[r.kind] = append(byKind[r.kind], r.ref)
+	}
+	s.byTime = mergeRefs(s.byTime, sorted)
+	for kind, rs := range byKind {
+		s.byKind[kind] = mergeRefs(s.byKind[kind], rs)
+	}
+}
+
+// mergeRefs merges the sorted refs b into the sorted refs a. Appends are usually in
+// time order, so the common case is a plain append.
+func mergeRefs(a, b []ref) []ref {
+	if len(b) == 0 {
+		return a
+	}
+	n := len(a)
+	if n == 0 || !b[0].less(a[n-1]) {
+		return append(a, b...)
+	}
+	// Merge from the back so that a can be filled in place.
+	a = append(a, b...)
+	i, j := n-1, len(b)-1
+	for k := len(a) - 1; j >= 0; k-- {
+		if i >= 0 && b[j].less(a[i]) {
+			a[k] = a[i]
+			i--
+		} else {
+			a[k] = b[j]
+			j--
+		}
+	}
+	return a
+}
+
+func (s *Store) newSegment(id int) error {
+	f, err := os.OpenFile(s.segmentPath(id), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	seg := &segment{id: id, f: f}
+	s.segments[id] = seg
+	s.active = seg
+	return syncDir(s.dir)
+}
+
+// Append writes entries to the store. Nil entries are skipped. Each call's records
+// are written with a single write to the active segment. If any entry is too large to
+// store, Append returns ErrRecordTooLarge without writing anything.
+func (s *Store) Append(logs ...*netgearlogs.NetGearLog) error {
+	for _, l := range logs {
+		if l != nil && payloadSize(l) > maxRecordSize {
+			return ErrRecordTooLarge
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var buf []byte
+	var offsets []int64
+	var batch []*netgearlogs.NetGearLog
+	var refs []kindRef
+	defer func() { s.addRefs(refs) }()
+	flush := func() error {
+		if len(buf) == 0 {
+			return nil
+		}
+		seg := s.active
+		if _, err := seg.f.WriteAt(buf, seg.size); err != nil {
+			return err
+		}
+		if s.opts.SyncWrites {
+			if err := seg.f.Sync(); err != nil {
+				return err
+			}
+		}
+		for i, l := range batch {
+			refs = append(refs, s.index(seg, l, seg.size+offsets[i]))
+		}
+		seg.size += int64(len(buf))
+		buf, offsets, batch = buf[:0], offsets[:0], batch[:0]
+		return nil
+	}
+	for _, l := range logs {
+		if l == nil {
+			continue
+		}
+		if s.active.size+int64(len(buf)) >= s.opts.MaxSegmentSize && (s.active.size > 0 || len(buf) > 0) {
+			if err := flush(); err != nil {
+				return err
+			}
+			if err := s.active.f.Sync(); err != nil {
+				return err
+			}
+			if err := s.newSegment(s.active.id + 1); err != nil {
+				return err
+			}
+		}
+		offsets = append(offsets, int64(len(buf)))
+		batch = append(batch, l)
+		buf = encodeRecord(buf, l)
+	}
+	return flush()
+}
+
+// Query returns the entries with from <= Time < to, in time order, optionally limited
+// to the given event kinds (see NetGearLog.Kind). A zero from or to leaves that end
+// of the range open.
+func (s *Store) Query(from, to time.Time, kinds ...string) ([]*netgearlogs.NetGearLog, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var refs []ref
+	if len(kinds) == 0 {
+		refs = rangeRefs(s.byTime, from, to)
+	} else {
+		seen := make(map[string]bool)
+		for _, k := range kinds {
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			refs = append(refs, rangeRefs(s.byKind[k], from, to)...)
+		}
+		sort.Slice(refs, func(i, j int) bool { return refs[i].less(refs[j]) })
+	}
+	logs := make([]*netgearlogs.NetGearLog, 0, len(refs))
+	for _, r := range refs {
+		l, _, err := readRecord(s.segments[r.seg].f, r.off)
+		if err != nil {
+			return logs, fmt.Errorf("reading segment %d at offset %d: %s", r.seg, r.off, err)
+		}
+		logs = append(logs, l)
+	}
+	return logs, nil
+}
+
+func rangeRefs(refs []ref, from, to time.Time) []ref {
+	lo, hi := 0, len(refs)
+	if !from.IsZero() {
+		f := from.UnixNano()
+		lo = sort.Search(len(refs), func(i int) bool { return refs[i].t >= f })
+	}
+	if !to.IsZero() {
+		t := to.UnixNano()
+		hi = sort.Search(len(refs), func(i int) bool { return refs[i].t >= t })
+	}
+	if lo >= hi {
+		return nil
+	}
+	return refs[lo:hi]
+}
+
+// Len returns the number of entries in the store.
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.byTime)
+}
+
+// Compact drops entries older than the Retention window. It does nothing if no
+// retention is configured.
+func (s *Store) Compact() error {
+	if s.opts.Retention <= 0 {
+		return nil
+	}
+	return s.CompactBefore(s.now().Add(-s.opts.Retention))
+}
+
+// CompactBefore drops entries older than cutoff. Segments holding only older entries
+// are deleted; segments holding some are rewritten with the rest. The active segment
+// is sealed first so that new appends go to a fresh segment. The indexes are rebuilt
+// from the segment files afterwards, even if compaction failed part way.
+func (s *Store) CompactBefore(cutoff time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.compact(cutoff)
+	s.closeFiles()
+	if lerr := s.load(); err == nil {
+		err = lerr
+	}
+	return err
+}
+
+func (s *Store) compact(cutoff time.Time) error {
+	if s.active.size > 0 {
+		if err := s.active.f.Sync(); err != nil {
+			return err
+		}
+		if err := s.newSegment(s.active.id + 1); err != nil {
+			return err
+		}
+	}
+	ids := make([]int, 0, len(s.segments))
+	for id := range s.segments {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		seg := s.segments[id]
+		if seg == s.active || seg.entries == 0 || !seg.min.Before(cutoff) {
+			continue
+		}
+		if seg.max.Before(cutoff) {
+			seg.f.Close()
+			if err := os.Remove(seg.f.Name()); err != nil {
+				return err
+			}
+			delete(s.segments, id)
+			continue
+		}
+		if err := s.rewrite(seg, cutoff); err != nil {
+			return err
+		}
+	}
+	return syncDir(s.dir)
+}
+
+// rewrite replaces seg with a copy holding only the records at or after cutoff. The
+// copy is written and synced under a temporary name, then renamed over the original,
+// so a crash leaves either the old or the new segment in place.
+func (s *Store) rewrite(seg *segment, cutoff time.Time) error {
+	tmp := seg.f.Name() + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	var buf []byte
+	var off int64
+	for off < seg.size {
+		l, n, err := readRecord(seg.f, off)
+		if err != nil {
+			f.Close()
+			os.Remove(tmp)
+			return err
+		}
+		if !l.Time.Before(cutoff) {
+			buf = encodeRecord(buf, l)
+		}
+		off += n
+	}
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	f.Close()
+	seg.f.Close()
+	return os.Rename(tmp, seg.f.Name())
+}
+
+// Sync flushes the active segment to stable storage.
+func (s *Store) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active.f.Sync()
+}
+
+// Close syncs and closes the store.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.active.f.Sync()
+	if cerr := s.closeFiles(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (s *Store) closeFiles() error {
+	var err error
+	for _, seg := range s.segments {
+		if cerr := seg.f.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// syncDir fsyncs a directory so that file creations, renames and removals in it are
+// durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
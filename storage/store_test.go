@@ -0,0 +1,333 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	netgearlogs "github.com/klauern/go-netgearlogs"
+)
+
+var base = time.Date(2016, 2, 22, 12, 0, 0, 0, time.UTC)
+
+func testLogs(n int) []*netgearlogs.NetGearLog {
+	var logs []*netgearlogs.NetGearLog
+	for i := 0; i < n; i++ {
+		l := &netgearlogs.NetGearLog{Time: base.Add(time.Duration(i) * time.Minute)}
+		if i%2 == 0 {
+			l.EventType = "DoS Attack: ACK Scan"
+			l.FromSource = "195.179.119.177"
+			l.Port = 80
+		} else {
+			l.EventType = "DHCP IP"
+			l.FromSource = "192.168.1.2"
+			l.ToMACAddress = "54:26:96:d1:a0:2f"
+		}
+		logs = append(logs, l)
+	}
+	return logs
+}
+
+func openStore(t *testing.T, dir string, opts *Options) *Store {
+	s, err := Open(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestAppendQuery(t *testing.T) {
+	s := openStore(t, t.TempDir(), nil)
+	defer s.Close()
+	if err := s.Append(testLogs(10)...); err != nil {
+		t.Fatal(err)
+	}
+	all, err := s.Query(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 10 {
+		t.Fatalf("Expected 10 entries, got %d", len(all))
+	}
+	logs, err := s.Query(base.Add(2*time.Minute), base.Add(6*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 4 || !logs[0].Time.Equal(base.Add(2*time.Minute)) {
+		t.Errorf("Expected minutes 2 to 5, got %d entries starting %v", len(logs), logs)
+	}
+	dos, err := s.Query(time.Time{}, time.Time{}, "DoS Attack: ACK Scan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dos) != 5 {
+		t.Errorf("Expected 5 DoS entries, got %d", len(dos))
+	}
+	for _, l := range dos {
+		if l.Port != 80 || l.FromSource != "195.179.119.177" {
+			t.Errorf("Unexpected entry %+v", l)
+		}
+	}
+	both, err := s.Query(time.Time{}, base.Add(4*time.Minute), "DHCP IP", "DoS Attack: ACK Scan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(both) != 4 || !netgearlogs.LogsSorted(both) {
+		t.Errorf("Expected 4 entries in time order, got %d", len(both))
+	}
+}
+
+func TestAppendOutOfOrder(t *testing.T) {
+	s := openStore(t, t.TempDir(), nil)
+	defer s.Close()
+	logs := testLogs(6)
+	netgearlogs.ReverseLogs(logs)
+	if err := s.Append(logs...); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Query(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 6 || !netgearlogs.LogsSorted(got) {
+		t.Errorf("Expected 6 entries in time order, got %d", len(got))
+	}
+
+	// A second newest-first batch falling between the first batch's entries.
+	between := testLogs(6)
+	for _, l := range between {
+		l.Time = l.Time.Add(30 * time.Second)
+	}
+	netgearlogs.ReverseLogs(between)
+	if err := s.Append(between...); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		kinds []string
+		n     int
+	}{{nil, 12}, {[]string{"DHCP IP"}, 6}} {
+		got, err := s.Query(time.Time{}, time.Time{}, tc.kinds...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != tc.n || !netgearlogs.LogsSorted(got) {
+			t.Errorf("%v: expected %d interleaved entries in time order, got %d", tc.kinds, tc.n, len(got))
+		}
+	}
+}
+
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, dir, nil)
+	if err := s.Append(testLogs(8)...); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s = openStore(t, dir, nil)
+	defer s.Close()
+	if s.Len() != 8 {
+		t.Fatalf("Expected 8 entries after reopening, got %d", s.Len())
+	}
+	if err := s.Append(testLogs(1)...); err != nil {
+		t.Fatal(err)
+	}
+	dhcp, err := s.Query(time.Time{}, time.Time{}, "DHCP IP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dhcp) != 4 {
+		t.Errorf("Expected 4 DHCP entries, got %d", len(dhcp))
+	}
+}
+
+func TestAppendTooLarge(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, dir, nil)
+	huge := &netgearlogs.NetGearLog{Time: base, EventType: "email failed", Message: strings.Repeat("x", maxRecordSize)}
+	if err := s.Append(append(testLogs(2), huge)...); err != ErrRecordTooLarge {
+		t.Fatalf("Expected ErrRecordTooLarge, got %v", err)
+	}
+	if s.Len() != 0 {
+		t.Errorf("Expected nothing written, got %d entries", s.Len())
+	}
+	if err := s.Append(testLogs(2)...); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	s = openStore(t, dir, nil)
+	defer s.Close()
+	if s.Len() != 2 {
+		t.Errorf("Expected 2 entries after reopening, got %d", s.Len())
+	}
+}
+
+func TestOpenRemovesTemp(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, dir, nil)
+	if err := s.Append(testLogs(2)...); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	tmp := filepath.Join(dir, "segment-00000001.ngl.tmp")
+	if err := os.WriteFile(tmp, []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s = openStore(t, dir, nil)
+	defer s.Close()
+	if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+		t.Errorf("Expected the temporary file to be removed, got %v", err)
+	}
+	if s.Len() != 2 {
+		t.Errorf("Expected 2 entries, got %d", s.Len())
+	}
+}
+
+func TestTornTail(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, dir, nil)
+	if err := s.Append(testLogs(5)...); err != nil {
+		t.Fatal(err)
+	}
+	name := s.active.f.Name()
+	size := s.active.size
+	s.Close()
+
+	// Simulate a crash part way through writing a record.
+	rec := encodeRecord(nil, testLogs(1)[0])
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(rec[:len(rec)/2])
+	f.Close()
+
+	s = openStore(t, dir, nil)
+	defer s.Close()
+	if s.Len() != 5 {
+		t.Errorf("Expected 5 entries, got %d", s.Len())
+	}
+	if fi, err := os.Stat(name); err != nil || fi.Size() != size {
+		t.Errorf("Expected the torn record to be truncated to %d bytes, got %v %v", size, fi.Size(), err)
+	}
+	if err := s.Append(testLogs(2)...); err != nil {
+		t.Fatal(err)
+	}
+	if s.Len() != 7 {
+		t.Errorf("Expected 7 entries, got %d", s.Len())
+	}
+}
+
+func TestCorruptSealedSegment(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, dir, &Options{MaxSegmentSize: 200})
+	if err := s.Append(testLogs(10)...); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	first := filepath.Join(dir, "segment-00000001.ngl")
+	data, err := os.ReadFile(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-1] ^= 0xff
+	if err := os.WriteFile(first, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(dir, nil); err == nil {
+		t.Error("Expected an error for a corrupt sealed segment")
+	}
+}
+
+func TestSegmentRolling(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, dir, &Options{MaxSegmentSize: 200})
+	defer s.Close()
+	for _, l := range testLogs(10) {
+		if err := s.Append(l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(s.segments) < 3 {
+		t.Errorf("Expected several segments, got %d", len(s.segments))
+	}
+	for _, seg := range s.segments {
+		if seg.entries > 1 && seg.size > 200+int64(len(encodeRecord(nil, testLogs(1)[0]))) {
+			t.Errorf("Segment %d grew to %d bytes", seg.id, seg.size)
+		}
+	}
+	logs, err := s.Query(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 10 || !netgearlogs.LogsSorted(logs) {
+		t.Errorf("Expected 10 entries in time order, got %d", len(logs))
+	}
+}
+
+func TestCompact(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, dir, &Options{MaxSegmentSize: 300, Retention: time.Hour})
+	if err := s.Append(testLogs(20)...); err != nil {
+		t.Fatal(err)
+	}
+	s.now = func() time.Time { return base.Add(time.Hour + 5*time.Minute + 30*time.Second) }
+	before := len(s.segments)
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Len() != 14 {
+		t.Errorf("Expected 14 entries to remain, got %d", s.Len())
+	}
+	if len(s.segments) >= before+1 {
+		t.Errorf("Expected expired segments to be removed, had %d now %d", before, len(s.segments))
+	}
+	logs, err := s.Query(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) == 0 || !logs[0].Time.Equal(base.Add(6*time.Minute)) {
+		t.Errorf("Expected the oldest entry to be minute 6, got %v", logs)
+	}
+	if err := s.Append(testLogs(1)...); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s = openStore(t, dir, nil)
+	defer s.Close()
+	if s.Len() != 15 {
+		t.Errorf("Expected 15 entries after reopening, got %d", s.Len())
+	}
+}
+
+func TestCompactFailure(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, dir, &Options{MaxSegmentSize: 200})
+	defer s.Close()
+	if err := s.Append(testLogs(20)...); err != nil {
+		t.Fatal(err)
+	}
+	// The first segment expires outright; the second cannot be rewritten because its
+	// temporary copy's name is taken by a directory.
+	seg := s.segments[2]
+	if err := os.Mkdir(seg.f.Name()+".tmp", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CompactBefore(seg.max); err == nil {
+		t.Fatal("Expected the rewrite to fail")
+	}
+	if _, ok := s.segments[1]; ok {
+		t.Error("Expected the expired segment to be removed")
+	}
+	logs, err := s.Query(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != s.Len() || !logs[0].Time.Equal(seg.min) {
+		t.Errorf("Expected the indexes to match the remaining segments, got %d of %d entries", len(logs), s.Len())
+	}
+}
@@ -0,0 +1,161 @@
+package netgearlogs
+
+import (
+	"sort"
+	"time"
+)
+
+// Default thresholds for flagging UPnP hosts.
+const (
+	DefaultUPnPMaxChurn    = 10.0
+	DefaultUPnPMaxLifetime = 24 * time.Hour
+)
+
+// UPnPOptions set when AnalyzeUPnP flags a host.
+type UPnPOptions struct {
+	// MaxChurn is the number of mappings a host may add per hour of log before it is
+	// flagged as churning. Defaults to DefaultUPnPMaxChurn.
+	MaxChurn float64
+	// MaxLifetime is how long a mapping may stay open before its host is flagged.
+	// Defaults to DefaultUPnPMaxLifetime.
+	MaxLifetime time.Duration
+}
+
+// UPnPMapping is a NAT rule added by a LAN host through UPnP.
+type UPnPMapping struct {
+	Added time.Time
+	// Deleted is when the rule was removed. For a rule still open at the end of the
+	// log it is the time of the last entry.
+	Deleted time.Time
+	Open    bool
+}
+
+// Lifetime returns how long the mapping was open.
+func (m UPnPMapping) Lifetime() time.Duration { return m.Deleted.Sub(m.Added) }
+
+// UPnPHost summarises the UPnP activity of one LAN host.
+type UPnPHost struct {
+	Host    string
+	Adds    int
+	Deletes int
+	// UnmatchedDeletes counts deletes with no open mapping to close, usually rules
+	// added before the log starts.
+	UnmatchedDeletes int
+	Mappings         []UPnPMapping
+	MeanLifetime     time.Duration
+	MaxLifetime      time.Duration
+	// Churn is the number of mappings added per hour of log.
+	Churn float64
+	// Churning and LongLived report whether the host crossed the UPnPOptions
+	// thresholds.
+	Churning  bool
+	LongLived bool
+}
+
+// Flagged reports whether the host crossed either threshold.
+func (h UPnPHost) Flagged() bool { return h.Churning || h.LongLived }
+
+// UPnPReport is the UPnP mapping activity in a log, by LAN host.
+type UPnPReport struct {
+	// Start and End are the times of the first and last entries of the log.
+	Start, End time.Time
+	// Hosts are ordered by address.
+	Hosts []UPnPHost
+}
+
+// Flagged returns the hosts that crossed either threshold.
+func (r *UPnPReport) Flagged() []UPnPHost {
+	var flagged []UPnPHost
+	for _, h := range r.Hosts {
+		if h.Flagged() {
+			flagged = append(flagged, h)
+		}
+	}
+	return flagged
+}
+
+// AnalyzeUPnP pairs the UPnP add_nat_rule and del_nat_rule entries in logs into
+// mappings per LAN host. logs may be in ascending order or newest first, as the router
+// exports them. The router does not log which rule an entry refers to, so each delete
+// closes the host's oldest open mapping. Overlapping exports should be merged with
+// DedupLogs first, or repeated entries will be counted twice. If opts is nil the
+// defaults are used.
+func AnalyzeUPnP(logs []*NetGearLog, opts *UPnPOptions) *UPnPReport {
+	o := UPnPOptions{MaxChurn: DefaultUPnPMaxChurn, MaxLifetime: DefaultUPnPMaxLifetime}
+	if opts != nil {
+		if opts.MaxChurn > 0 {
+			o.MaxChurn = opts.MaxChurn
+		}
+		if opts.MaxLifetime > 0 {
+			o.MaxLifetime = opts.MaxLifetime
+		}
+	}
+	sorted := make([]*NetGearLog, 0, len(logs))
+	for _, l := range logs {
+		if l != nil {
+			sorted = append(sorted, l)
+		}
+	}
+	sortOccurrences(sorted)
+
+	r := &UPnPReport{}
+	if len(sorted) == 0 {
+		return r
+	}
+	r.Start, r.End = sorted[0].Time, sorted[len(sorted)-1].Time
+
+	hosts := make(map[string]*UPnPHost)
+	// open holds the indexes into a host's Mappings of its open mappings, oldest first.
+	open := make(map[string][]int)
+	for _, l := range sorted {
+		kind := l.Kind()
+		if kind != eventUPnPAddNatRule && kind != eventUPnPDelNatRule {
+			continue
+		}
+		host := l.SourceIP()
+		h, ok := hosts[host]
+		if !ok {
+			h = &UPnPHost{Host: host}
+			hosts[host] = h
+		}
+		if kind == eventUPnPAddNatRule {
+			h.Adds++
+			open[host] = append(open[host], len(h.Mappings))
+			h.Mappings = append(h.Mappings, UPnPMapping{Added: l.Time})
+			continue
+		}
+		h.Deletes++
+		if len(open[host]) == 0 {
+			h.UnmatchedDeletes++
+			continue
+		}
+		h.Mappings[open[host][0]].Deleted = l.Time
+		open[host] = open[host][1:]
+	}
+
+	hours := r.End.Sub(r.Start).Hours()
+	if hours < 1 {
+		hours = 1
+	}
+	for host, h := range hosts {
+		for _, i := range open[host] {
+			h.Mappings[i].Deleted, h.Mappings[i].Open = r.End, true
+		}
+		var total time.Duration
+		for _, m := range h.Mappings {
+			total += m.Lifetime()
+			if m.Lifetime() > h.MaxLifetime {
+				h.MaxLifetime = m.Lifetime()
+			}
+		}
+		if len(h.Mappings) > 0 {
+			h.MeanLifetime = total / time.Duration(len(h.Mappings))
+		}
+		h.Churn = float64(h.Adds) / hours
+		h.Churning = h.Churn > o.MaxChurn
+		h.LongLived = h.MaxLifetime > o.MaxLifetime
+		r.Hosts = append(r.Hosts, *h)
+	}
+	sort.Slice(r.Hosts, func(i, j int) bool { return compareAddrs(r.Hosts[i].Host, r.Hosts[j].Host) < 0 })
+	return r
+}
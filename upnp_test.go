@@ -0,0 +1,96 @@
+package netgearlogs
+
+import (
+	"testing"
+	"time"
+)
+
+var upnpTestLines = []string{
+	"[UPnP set event: del_nat_rule] from source 192.168.1.8, Saturday, February 20, 2016 19:29:12",
+	"[UPnP set event: add_nat_rule] from source 192.168.1.8, Saturday, February 20, 2016 19:28:32",
+	"[UPnP set event: del_nat_rule] from source 192.168.1.8, Saturday, February 20, 2016 19:28:31",
+	"[UPnP set event: add_nat_rule] from source 192.168.1.8, Saturday, February 20, 2016 19:27:56",
+	"[UPnP set event: add_nat_rule] from source 192.168.1.20, Saturday, February 20, 2016 19:00:00",
+	"[UPnP set event: del_nat_rule] from source 192.168.1.8, Saturday, February 20, 2016 18:00:00",
+	"[Time synchronized with NTP server] Friday, February 19, 2016 19:03:13",
+}
+
+func TestAnalyzeUPnP(t *testing.T) {
+	r := AnalyzeUPnP(parseLines(upnpTestLines), nil)
+	if r.End.Sub(r.Start) != 24*time.Hour+25*time.Minute+59*time.Second {
+		t.Errorf("Unexpected report window %s to %s", r.Start, r.End)
+	}
+	if len(r.Hosts) != 2 || r.Hosts[0].Host != "192.168.1.8" || r.Hosts[1].Host != "192.168.1.20" {
+		t.Fatalf("Expected hosts in address order, got %+v", r.Hosts)
+	}
+	h := r.Hosts[0]
+	if h.Adds != 2 || h.Deletes != 3 || h.UnmatchedDeletes != 1 || len(h.Mappings) != 2 {
+		t.Errorf("Unexpected counts %+v", h)
+	}
+	if h.Mappings[0].Lifetime() != 35*time.Second || h.Mappings[1].Lifetime() != 40*time.Second {
+		t.Errorf("Unexpected lifetimes %+v", h.Mappings)
+	}
+	if h.MeanLifetime != 37500*time.Millisecond || h.MaxLifetime != 40*time.Second || h.Flagged() {
+		t.Errorf("Unexpected summary %+v", h)
+	}
+	open := r.Hosts[1]
+	if len(open.Mappings) != 1 || !open.Mappings[0].Open || open.MaxLifetime != 29*time.Minute+12*time.Second {
+		t.Errorf("Expected a mapping open to the end of the log, got %+v", open)
+	}
+}
+
+func TestAnalyzeUPnPFlags(t *testing.T) {
+	r := AnalyzeUPnP(parseLines(upnpTestLines), &UPnPOptions{MaxChurn: 0.05, MaxLifetime: 10 * time.Minute})
+	flagged := r.Flagged()
+	if len(flagged) != 2 {
+		t.Fatalf("Expected both hosts to be flagged, got %+v", flagged)
+	}
+	if !flagged[0].Churning || flagged[0].LongLived {
+		t.Errorf("Expected 192.168.1.8 to be churning, got %+v", flagged[0])
+	}
+	if flagged[1].Churning || !flagged[1].LongLived {
+		t.Errorf("Expected 192.168.1.20 to hold a long-lived mapping, got %+v", flagged[1])
+	}
+}
+
+func TestAnalyzeUPnPLogFile(t *testing.T) {
+	logs := parseLines(readLogLines(t))
+	r := AnalyzeUPnP(DedupLogs(logs), nil)
+	adds := 0
+	for _, h := range r.Hosts {
+		adds += h.Adds
+		if h.Adds != len(h.Mappings) {
+			t.Errorf("%s: expected a mapping per add, got %d for %d", h.Host, len(h.Mappings), h.Adds)
+		}
+	}
+	if adds == 0 {
+		t.Error("Expected UPnP mappings in log.txt")
+	}
+}
+
+func TestAnalyzeUPnPSameSecond(t *testing.T) {
+	// Newest first, as exported: the delete at 18:47:15 happened before the add.
+	lines := []string{
+		"[UPnP set event: add_nat_rule] from source 192.168.1.8, Saturday, February 20, 2016 18:47:15",
+		"[UPnP set event: del_nat_rule] from source 192.168.1.8, Saturday, February 20, 2016 18:47:15",
+	}
+	r := AnalyzeUPnP(parseLines(lines), nil)
+	if len(r.Hosts) != 1 {
+		t.Fatalf("Expected 1 host, got %+v", r.Hosts)
+	}
+	h := r.Hosts[0]
+	if h.UnmatchedDeletes != 1 || len(h.Mappings) != 1 || !h.Mappings[0].Open {
+		t.Errorf("Expected the delete to come first and the add to stay open, got %+v", h)
+	}
+
+	// The same entries in ascending order, after an earlier add, keep their order.
+	asc := parseLines([]string{
+		"[UPnP set event: add_nat_rule] from source 192.168.1.8, Saturday, February 20, 2016 18:46:30",
+		lines[1],
+		lines[0],
+	})
+	h = AnalyzeUPnP(asc, nil).Hosts[0]
+	if h.UnmatchedDeletes != 0 || len(h.Mappings) != 2 || h.Mappings[0].Lifetime() != 45*time.Second || !h.Mappings[1].Open {
+		t.Errorf("Unexpected mappings from ascending input %+v", h)
+	}
+}
@@ -0,0 +1,270 @@
+package netgearlogs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// wanDroppedMessage is the reason given by email failed entries when the router could
+// not send mail because the WAN was down.
+const wanDroppedMessage = "internet connection is dropped"
+
+// WANSession is a period in which the WAN was up with a single public address.
+type WANSession struct {
+	IP         string
+	Start, End time.Time
+	// Ongoing is set if the session was still up at the end of the log, in which case
+	// End is the time of the last entry.
+	Ongoing bool
+}
+
+// Duration returns the length of the session.
+func (s WANSession) Duration() time.Duration { return s.End.Sub(s.Start) }
+
+// WANOutage is a period in which the WAN was down. It starts at an email failed entry
+// reporting the connection dropped and ends when the router next reports Internet
+// connected.
+type WANOutage struct {
+	Start, End time.Time
+	// Ongoing is set if the WAN was still down at the end of the log, in which case End
+	// is the time of the last entry.
+	Ongoing bool
+}
+
+// Duration returns the length of the outage.
+func (o WANOutage) Duration() time.Duration { return o.End.Sub(o.Start) }
+
+// WANAddressChange records the router being given a different public address.
+type WANAddressChange struct {
+	Time     time.Time
+	From, To string
+}
+
+// WANDay is the WAN availability over one calendar day. Time before the first WAN
+// entry in the log is not counted, as the state of the WAN is not known.
+type WANDay struct {
+	Day      time.Time
+	Up, Down time.Duration
+}
+
+// Uptime returns the percentage of the day's known time in which the WAN was up, or 0
+// if none of the day is known.
+func (d WANDay) Uptime() float64 {
+	if d.Up+d.Down == 0 {
+		return 0
+	}
+	return 100 * float64(d.Up) / float64(d.Up+d.Down)
+}
+
+// WANTimeline is the history of the WAN connection reconstructed from a log.
+type WANTimeline struct {
+	// Start and End bound the period the timeline knows about: from the first WAN
+	// entry to the last entry of any kind. Both are zero if there were no WAN entries.
+	Start, End time.Time
+	Sessions   []WANSession
+	Outages    []WANOutage
+	Changes    []WANAddressChange
+	Days       []WANDay
+}
+
+// AnalyzeWAN reconstructs the WAN history from the Internet connected and email failed
+// entries in logs, which may be in ascending order or newest first. Internet connected
+// marks the WAN as up with the given address; the router logs it when the WAN comes
+// up and again at every lease renewal. An email failed entry reporting that the
+// internet connection dropped marks it as down. Days are calendar days in loc, or UTC
+// if loc is nil.
+func AnalyzeWAN(logs []*NetGearLog, loc *time.Location) *WANTimeline {
+	if loc == nil {
+		loc = time.UTC
+	}
+	sorted := make([]*NetGearLog, 0, len(logs))
+	for _, l := range logs {
+		if l != nil {
+			sorted = append(sorted, l)
+		}
+	}
+	sortOccurrences(sorted)
+
+	tl := &WANTimeline{}
+	const (
+		unknown = iota
+		up
+		down
+	)
+	state := unknown
+	var session WANSession
+	var outage WANOutage
+	lastIP := ""
+	for _, l := range sorted {
+		tl.End = l.Time
+		switch {
+		case l.Kind() == eventInternetConnected:
+			ip := l.FromSource
+			if lastIP != "" && ip != lastIP {
+				tl.Changes = append(tl.Changes, WANAddressChange{Time: l.Time, From: lastIP, To: ip})
+			}
+			lastIP = ip
+			switch state {
+			case unknown:
+				tl.Start = l.Time
+			case down:
+				outage.End = l.Time
+				tl.Outages = append(tl.Outages, outage)
+			case up:
+				if ip == session.IP {
+					continue
+				}
+				session.End = l.Time
+				tl.Sessions = append(tl.Sessions, session)
+			}
+			session = WANSession{IP: ip, Start: l.Time}
+			state = up
+		case l.Kind() == eventEmailFailed && strings.Contains(strings.ToLower(l.Message), wanDroppedMessage):
+			switch state {
+			case unknown:
+				tl.Start = l.Time
+			case down:
+				continue
+			case up:
+				session.End = l.Time
+				tl.Sessions = append(tl.Sessions, session)
+			}
+			outage = WANOutage{Start: l.Time}
+			state = down
+		}
+	}
+	switch state {
+	case unknown:
+		// With no WAN entries nothing is known, not even when the timeline ends.
+		tl.End = time.Time{}
+	case up:
+		session.End, session.Ongoing = tl.End, true
+		tl.Sessions = append(tl.Sessions, session)
+	case down:
+		outage.End, outage.Ongoing = tl.End, true
+		tl.Outages = append(tl.Outages, outage)
+	}
+
+	days := make(map[time.Time]*WANDay)
+	for _, s := range tl.Sessions {
+		splitDays(s.Start, s.End, loc, func(day time.Time, d time.Duration) { wanDay(days, day).Up += d })
+	}
+	for _, o := range tl.Outages {
+		splitDays(o.Start, o.End, loc, func(day time.Time, d time.Duration) { wanDay(days, day).Down += d })
+	}
+	for _, d := range days {
+		tl.Days = append(tl.Days, *d)
+	}
+	sort.Slice(tl.Days, func(i, j int) bool { return tl.Days[i].Day.Before(tl.Days[j].Day) })
+	return tl
+}
+
+func wanDay(days map[time.Time]*WANDay, day time.Time) *WANDay {
+	d, ok := days[day]
+	if !ok {
+		d = &WANDay{Day: day}
+		days[day] = d
+	}
+	return d
+}
+
+// splitDays calls fn with the start of each calendar day in loc that [start, end)
+// overlaps, and the length of the overlap.
+func splitDays(start, end time.Time, loc *time.Location, fn func(day time.Time, d time.Duration)) {
+	for start.Before(end) {
+		s := start.In(loc)
+		day := time.Date(s.Year(), s.Month(), s.Day(), 0, 0, 0, 0, loc)
+		next := time.Date(s.Year(), s.Month(), s.Day()+1, 0, 0, 0, 0, loc)
+		if next.After(end) {
+			next = end
+		}
+		fn(day, next.Sub(start))
+		start = next
+	}
+}
+
+// WANSummary describes the reliability of the ISP over a timeline.
+type WANSummary struct {
+	// Observed is the length of the timeline, and Uptime the percentage of it in
+	// which the WAN was up.
+	Observed time.Duration
+	Uptime   float64
+	// Outages is the number of outages, and Downtime their total length.
+	Outages       int
+	Downtime      time.Duration
+	LongestOutage time.Duration
+	// MTBF is the mean time between failures, the up time divided by the number of
+	// outages, and MTTR the mean time to recover, the mean outage length. Both are 0
+	// if there were no outages.
+	MTBF, MTTR time.Duration
+	// Addresses are the distinct public addresses the WAN was given, in the order
+	// they were first seen, and AddressChanges the number of times it changed.
+	Addresses      []string
+	AddressChanges int
+}
+
+// Summary summarises the timeline. An empty timeline has zero uptime.
+func (tl *WANTimeline) Summary() WANSummary {
+	s := WANSummary{Outages: len(tl.Outages), AddressChanges: len(tl.Changes)}
+	if !tl.Start.IsZero() && !tl.End.IsZero() {
+		s.Observed = tl.End.Sub(tl.Start)
+	}
+	var up time.Duration
+	seen := make(map[string]bool)
+	for _, ses := range tl.Sessions {
+		up += ses.Duration()
+		if !seen[ses.IP] {
+			seen[ses.IP] = true
+			s.Addresses = append(s.Addresses, ses.IP)
+		}
+	}
+	for _, o := range tl.Outages {
+		s.Downtime += o.Duration()
+		if o.Duration() > s.LongestOutage {
+			s.LongestOutage = o.Duration()
+		}
+	}
+	if up+s.Downtime > 0 {
+		s.Uptime = 100 * float64(up) / float64(up+s.Downtime)
+	}
+	if s.Outages > 0 {
+		s.MTBF = up / time.Duration(s.Outages)
+		s.MTTR = s.Downtime / time.Duration(s.Outages)
+	}
+	return s
+}
+
+// WriteReport writes the timeline and its summary as text.
+func (tl *WANTimeline) WriteReport(w io.Writer) error {
+	s := tl.Summary()
+	// bufio.Writer keeps the first write error and returns it from Flush.
+	bw := bufio.NewWriter(w)
+	if tl.Start.IsZero() {
+		fmt.Fprintln(bw, "WAN: no connection entries")
+		return bw.Flush()
+	}
+	fmt.Fprintf(bw, "WAN from %s to %s (%s)\n", tl.Start.Format(reportTimeFmt), tl.End.Format(reportTimeFmt), s.Observed.Round(time.Second))
+	fmt.Fprintf(bw, "Uptime %.2f%%, %d outages totalling %s, longest %s\n", s.Uptime, s.Outages, s.Downtime.Round(time.Second), s.LongestOutage.Round(time.Second))
+	if s.Outages > 0 {
+		fmt.Fprintf(bw, "MTBF %s, MTTR %s\n", s.MTBF.Round(time.Second), s.MTTR.Round(time.Second))
+	}
+	fmt.Fprintf(bw, "Addresses %s, %d changes\n", strings.Join(s.Addresses, ", "), s.AddressChanges)
+	for _, o := range tl.Outages {
+		end := o.End.Format(reportTimeFmt)
+		if o.Ongoing {
+			end = "ongoing"
+		}
+		fmt.Fprintf(bw, "  down %s to %s (%s)\n", o.Start.Format(reportTimeFmt), end, o.Duration().Round(time.Second))
+	}
+	for _, c := range tl.Changes {
+		fmt.Fprintf(bw, "  address %s -> %s at %s\n", c.From, c.To, c.Time.Format(reportTimeFmt))
+	}
+	for _, d := range tl.Days {
+		fmt.Fprintf(bw, "  %s %6.2f%%\n", d.Day.Format("2006-01-02"), d.Uptime())
+	}
+	return bw.Flush()
+}
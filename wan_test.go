@@ -0,0 +1,128 @@
+package netgearlogs
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+var wanTestLines = []string{
+	"[Internet connected] IP address: 96.37.90.30, Tuesday, February 23, 2016 06:00:00",
+	"[DoS Attack: ACK Scan] from source: 195.179.119.177, port 80, Tuesday, February 23, 2016 03:00:00",
+	"[email failed] internet connection is dropped, Tuesday, February 23, 2016 00:00:00",
+	"[email failed] internet connection is dropped, Monday, February 22, 2016 23:00:00",
+	"[Internet connected] IP address: 96.37.90.24, Monday, February 22, 2016 18:00:00",
+	"[Internet connected] IP address: 96.37.90.24, Monday, February 22, 2016 14:00:00",
+	"[email failed] internet connection is dropped, Monday, February 22, 2016 13:00:00",
+	"[Internet connected] IP address: 96.37.90.24, Monday, February 22, 2016 12:00:00",
+	"[DoS Attack: ACK Scan] from source: 195.179.119.177, port 80, Monday, February 22, 2016 11:00:00",
+}
+
+func TestAnalyzeWAN(t *testing.T) {
+	tl := AnalyzeWAN(parseLines(wanTestLines), nil)
+	if tl.Start.Hour() != 12 || tl.End.Day() != 23 || tl.End.Hour() != 6 {
+		t.Errorf("Unexpected timeline bounds %s to %s", tl.Start, tl.End)
+	}
+	if len(tl.Sessions) != 3 {
+		t.Fatalf("Expected 3 sessions, got %+v", tl.Sessions)
+	}
+	if s := tl.Sessions[1]; s.IP != "96.37.90.24" || s.Duration() != 9*time.Hour {
+		t.Errorf("Expected a 9 hour session spanning the renewal, got %+v", s)
+	}
+	if s := tl.Sessions[2]; s.IP != "96.37.90.30" || !s.Ongoing || s.Duration() != 0 {
+		t.Errorf("Unexpected last session %+v", s)
+	}
+	if len(tl.Outages) != 2 || tl.Outages[0].Duration() != time.Hour || tl.Outages[1].Duration() != 7*time.Hour {
+		t.Errorf("Expected outages of 1h and 7h, got %+v", tl.Outages)
+	}
+	if len(tl.Changes) != 1 || tl.Changes[0].From != "96.37.90.24" || tl.Changes[0].To != "96.37.90.30" {
+		t.Errorf("Unexpected address changes %+v", tl.Changes)
+	}
+	if len(tl.Days) != 2 {
+		t.Fatalf("Expected 2 days, got %+v", tl.Days)
+	}
+	if d := tl.Days[0]; d.Up != 10*time.Hour || d.Down != 2*time.Hour || d.Uptime() < 83.3 || d.Uptime() > 83.4 {
+		t.Errorf("Unexpected first day %+v", d)
+	}
+	if d := tl.Days[1]; d.Up != 0 || d.Down != 6*time.Hour || d.Uptime() != 0 {
+		t.Errorf("Unexpected second day %+v", d)
+	}
+
+	s := tl.Summary()
+	if s.Outages != 2 || s.Downtime != 8*time.Hour || s.LongestOutage != 7*time.Hour {
+		t.Errorf("Unexpected outage summary %+v", s)
+	}
+	if s.MTBF != 5*time.Hour || s.MTTR != 4*time.Hour || s.Uptime < 55.5 || s.Uptime > 55.6 {
+		t.Errorf("Unexpected reliability summary %+v", s)
+	}
+	if len(s.Addresses) != 2 || s.AddressChanges != 1 {
+		t.Errorf("Unexpected addresses %+v", s)
+	}
+}
+
+func TestAnalyzeWANSameSecond(t *testing.T) {
+	// Newest first, as exported: the WAN dropped at 12:00 and came back in the same second.
+	tl := AnalyzeWAN(parseLines([]string{
+		"[Internet connected] IP address: 96.37.90.24, Monday, February 22, 2016 12:00:00",
+		"[email failed] internet connection is dropped, Monday, February 22, 2016 12:00:00",
+		"[Internet connected] IP address: 96.37.90.24, Monday, February 22, 2016 10:00:00",
+	}), nil)
+	if len(tl.Outages) != 1 || tl.Outages[0].Ongoing || tl.Outages[0].Duration() != 0 {
+		t.Errorf("Expected a closed outage at 12:00, got %+v", tl.Outages)
+	}
+	if len(tl.Sessions) != 2 || !tl.Sessions[1].Ongoing {
+		t.Errorf("Expected the WAN to end up, got %+v", tl.Sessions)
+	}
+}
+
+func TestAnalyzeWANLocation(t *testing.T) {
+	tl := AnalyzeWAN(parseLines(wanTestLines), time.FixedZone("UTC+12", 12*60*60))
+	// 12:00 UTC on the 22nd is midnight on the 23rd at UTC+12.
+	if len(tl.Days) != 1 || tl.Days[0].Day.Day() != 23 || tl.Days[0].Up != 10*time.Hour || tl.Days[0].Down != 8*time.Hour {
+		t.Errorf("Expected days to follow the location, got %+v", tl.Days)
+	}
+}
+
+func TestAnalyzeWANLogFile(t *testing.T) {
+	tl := AnalyzeWAN(parseLines(readLogLines(t)), nil)
+	s := tl.Summary()
+	if len(s.Addresses) == 0 || s.Addresses[0] != "96.37.90.24" {
+		t.Errorf("Unexpected addresses %v", s.Addresses)
+	}
+	if s.Outages != 1 || !tl.Outages[0].Ongoing {
+		t.Errorf("Expected the log to end with the connection dropped, got %+v", tl.Outages)
+	}
+}
+
+func TestWANReport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := AnalyzeWAN(parseLines(wanTestLines), nil).WriteReport(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"2 outages totalling 8h0m0s", "MTBF 5h0m0s, MTTR 4h0m0s", "96.37.90.24 -> 96.37.90.30", "2016-02-22  83.33%"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected the report to contain %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestAnalyzeWANEmpty(t *testing.T) {
+	for _, logs := range [][]*NetGearLog{nil, parseLines(wanTestLines[1:2])} {
+		tl := AnalyzeWAN(logs, nil)
+		if !tl.Start.IsZero() || !tl.End.IsZero() {
+			t.Errorf("Expected an empty timeline, got %s to %s", tl.Start, tl.End)
+		}
+		s := tl.Summary()
+		if s.Observed != 0 || s.Uptime != 0 || s.Outages != 0 {
+			t.Errorf("Expected an empty summary, got %+v", s)
+		}
+		var buf bytes.Buffer
+		if err := tl.WriteReport(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != "WAN: no connection entries\n" {
+			t.Errorf("Unexpected report %q", buf.String())
+		}
+	}
+}